@@ -1,7 +1,7 @@
 package retrygo
 
 import (
-	"math/rand"
+	"math"
 	"time"
 )
 
@@ -26,21 +26,141 @@ func Linear(interval time.Duration) RetryPolicy {
 }
 
 // Exponential returns a RetryPolicy that increases the interval between
-// retries exponentially.
+// retries exponentially. The sleep saturates instead of overflowing.
 //
-// Sleep formula: interval * 2^fails
+// Sleep formula: interval * 2^(fails-1)
 func Exponential(interval time.Duration) RetryPolicy {
+	return ExponentialWith(interval, ExponentialOptions{})
+}
+
+// ExponentialOptions configures ExponentialWith.
+type ExponentialOptions struct {
+	Multiplier  float64       // Multiplier is the growth factor, 2 if not positive
+	Max         time.Duration // Max caps the sleep, no cap if not positive
+	MaxExponent int           // MaxExponent caps the exponent, no cap if not positive
+}
+
+// ExponentialWith returns a RetryPolicy that increases the interval between
+// retries exponentially with any real-valued multiplier. The sleep saturates
+// at Max, or at the longest time.Duration, instead of overflowing.
+//
+// Sleep formula: min(interval * multiplier^min(fails-1, maxExponent), max)
+func ExponentialWith(interval time.Duration, opts ExponentialOptions) RetryPolicy {
+	multiplier := opts.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	limit := time.Duration(math.MaxInt64)
+	if opts.Max > 0 {
+		limit = opts.Max
+	}
 	return func(ri RetryInfo) (bool, time.Duration) {
-		return true, interval * time.Duration(1<<uint(ri.Fails-1))
+		exponent := max(ri.Fails-1, 0)
+		if opts.MaxExponent > 0 {
+			exponent = min(exponent, opts.MaxExponent)
+		}
+		return true, min(saturate(float64(interval)*math.Pow(multiplier, float64(exponent))), limit)
 	}
 }
 
 // Jitter returns a RetryPolicy that adds a random non-negative jitter to the interval
 // between retries.
 //
-// Sleep formula: interval + rand.Int63n(interval)
+// Sleep formula: interval + rand(0, interval)
 func Jitter(interval time.Duration) RetryPolicy {
 	return func(ri RetryInfo) (bool, time.Duration) {
-		return true, interval + time.Duration(rand.Int63n(int64(interval)))
+		return true, interval + randDuration(ri.random(), interval)
+	}
+}
+
+// Fibonacci returns a RetryPolicy that increases the interval between retries
+// following the Fibonacci sequence. The sleep saturates instead of
+// overflowing.
+//
+// Sleep formula: interval * fib(fails), where fib(1) = fib(2) = 1
+func Fibonacci(interval time.Duration) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		a, b := 0.0, 1.0
+		for i := 1; i < ri.Fails && b < math.MaxInt64; i++ {
+			a, b = b, a+b
+		}
+		if ri.Fails < 1 {
+			b = 0
+		}
+		return true, saturate(float64(interval) * b)
+	}
+}
+
+// Polynomial returns a RetryPolicy that increases the interval between retries
+// polynomially. The sleep saturates instead of overflowing.
+//
+// Sleep formula: interval * fails^k
+func Polynomial(interval time.Duration, k float64) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		return true, saturate(float64(interval) * math.Pow(float64(max(ri.Fails, 0)), k))
+	}
+}
+
+// FullJitter returns a RetryPolicy that sleeps a random time up to the
+// exponential backoff, as in the "full jitter" algorithm from the AWS
+// Architecture Blog. The exponential backoff is capped by limit if it is
+// positive.
+//
+// Sleep formula: rand(0, min(interval * 2^(fails-1), limit))
+func FullJitter(interval, limit time.Duration) RetryPolicy {
+	backoff := ExponentialWith(interval, ExponentialOptions{Max: limit})
+	return func(ri RetryInfo) (bool, time.Duration) {
+		_, sleep := backoff(ri)
+		return true, randDuration(ri.random(), sleep)
+	}
+}
+
+// EqualJitter returns a RetryPolicy that keeps half of the exponential backoff
+// and randomizes the other half, as in the "equal jitter" algorithm from the
+// AWS Architecture Blog. The exponential backoff is capped by limit if it is
+// positive.
+//
+// Sleep formula: v/2 + rand(0, v/2), where v = min(interval * 2^(fails-1), limit)
+func EqualJitter(interval, limit time.Duration) RetryPolicy {
+	backoff := ExponentialWith(interval, ExponentialOptions{Max: limit})
+	return func(ri RetryInfo) (bool, time.Duration) {
+		_, sleep := backoff(ri)
+		return true, sleep/2 + randDuration(ri.random(), sleep-sleep/2)
+	}
+}
+
+// DecorrelatedJitter returns a RetryPolicy that picks the sleep at random
+// between interval and three times the previous sleep, as in the "decorrelated
+// jitter" algorithm from the AWS Architecture Blog. The sleep is capped by
+// limit if it is positive.
+//
+// The policy remembers the previous sleep, every Do call gets its own state.
+// Don't use the returned policy twice in a policy tree, see Stateful.
+//
+// Sleep formula: min(rand(interval, previous * 3), limit), where previous starts at interval
+func DecorrelatedJitter(interval, limit time.Duration) RetryPolicy {
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	return Stateful(PolicyFunc(func() RetryPolicy {
+		previous := interval
+		return func(ri RetryInfo) (bool, time.Duration) {
+			upper := saturate(float64(previous) * 3)
+			previous = min(interval+randDuration(ri.random(), upper-interval), limit)
+			return true, previous
+		}
+	}))
+}
+
+// saturate converts d to a time.Duration, saturating at 0 and at the longest
+// time.Duration instead of overflowing.
+func saturate(d float64) time.Duration {
+	switch {
+	case math.IsNaN(d) || d <= 0:
+		return 0
+	case d >= math.MaxInt64:
+		return math.MaxInt64
+	default:
+		return time.Duration(d)
 	}
 }
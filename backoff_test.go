@@ -147,3 +147,191 @@ func TestJitter(t *testing.T) {
 		info.Fails++
 	}
 }
+
+// Test Exponential does not overflow
+func TestExponentialOverflow(t *testing.T) {
+	backoff := retrygo.Exponential(time.Second)
+	previous := time.Duration(0)
+	for fails := 1; fails < 200; fails++ {
+		_, sleep := backoff(retrygo.RetryInfo{Fails: fails})
+		if sleep < previous {
+			t.Fatalf("sleep decreased at fails=%d: %s < %s", fails, sleep, previous)
+		}
+		previous = sleep
+	}
+	if previous != math.MaxInt64 {
+		t.Errorf("expected the sleep to saturate, got %s", previous)
+	}
+}
+
+// Test ExponentialWith
+func TestExponentialWith(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		interval       time.Duration
+		opts           retrygo.ExponentialOptions
+		requiredValues []time.Duration
+	}{
+		{
+			name:           "Default",
+			interval:       time.Second,
+			requiredValues: []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:           "Multiplier",
+			interval:       time.Second,
+			opts:           retrygo.ExponentialOptions{Multiplier: 1.5},
+			requiredValues: []time.Duration{1000 * time.Millisecond, 1500 * time.Millisecond, 2250 * time.Millisecond, 3375 * time.Millisecond},
+		},
+		{
+			name:           "Max",
+			interval:       time.Second,
+			opts:           retrygo.ExponentialOptions{Multiplier: 3, Max: 5 * time.Second},
+			requiredValues: []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:           "MaxExponent",
+			interval:       time.Second,
+			opts:           retrygo.ExponentialOptions{MaxExponent: 2},
+			requiredValues: []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second},
+		},
+		{
+			name:           "Zero",
+			interval:       0,
+			requiredValues: []time.Duration{0, 0, 0, 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backoff := retrygo.ExponentialWith(tc.interval, tc.opts)
+			info := retrygo.RetryInfo{Fails: 1}
+			for _, expectedValue := range tc.requiredValues {
+				_, sleep := backoff(info)
+				if sleep != expectedValue {
+					t.Errorf("expected %s, got %s", expectedValue, sleep)
+				}
+				info.Fails++
+			}
+		})
+	}
+}
+
+// Test Fibonacci and Polynomial
+func TestDeterministicBackoffs(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		backoff        retrygo.RetryPolicy
+		requiredValues []time.Duration
+	}{
+		{
+			name:           "Fibonacci",
+			backoff:        retrygo.Fibonacci(time.Second),
+			requiredValues: []time.Duration{1 * time.Second, 1 * time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second},
+		},
+		{
+			name:           "PolynomialSquare",
+			backoff:        retrygo.Polynomial(time.Second, 2),
+			requiredValues: []time.Duration{1 * time.Second, 4 * time.Second, 9 * time.Second, 16 * time.Second},
+		},
+		{
+			name:           "PolynomialSqrt",
+			backoff:        retrygo.Polynomial(time.Second, 0.5),
+			requiredValues: []time.Duration{1 * time.Second, 1414213562, 1732050807, 2 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := retrygo.RetryInfo{Fails: 1}
+			for _, expectedValue := range tc.requiredValues {
+				_, sleep := tc.backoff(info)
+				if sleep != expectedValue {
+					t.Errorf("expected %s, got %s", expectedValue, sleep)
+				}
+				info.Fails++
+			}
+		})
+	}
+}
+
+// Test Fibonacci and Polynomial do not overflow
+func TestDeterministicBackoffsOverflow(t *testing.T) {
+	for name, backoff := range map[string]retrygo.RetryPolicy{
+		"Fibonacci":  retrygo.Fibonacci(time.Second),
+		"Polynomial": retrygo.Polynomial(time.Second, 3),
+	} {
+		_, sleep := backoff(retrygo.RetryInfo{Fails: 1 << 20})
+		if sleep != math.MaxInt64 {
+			t.Errorf("%s: expected the sleep to saturate, got %s", name, sleep)
+		}
+	}
+}
+
+// Test FullJitter, EqualJitter and DecorrelatedJitter
+func TestJitterBackoffs(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	const limit = time.Second
+	for _, tc := range []struct {
+		name    string
+		backoff retrygo.RetryPolicy
+		bounds  func(fails int) (time.Duration, time.Duration)
+	}{
+		{
+			name:    "FullJitter",
+			backoff: retrygo.FullJitter(interval, limit),
+			bounds: func(fails int) (time.Duration, time.Duration) {
+				return 0, min(interval<<(fails-1), limit)
+			},
+		},
+		{
+			name:    "EqualJitter",
+			backoff: retrygo.EqualJitter(interval, limit),
+			bounds: func(fails int) (time.Duration, time.Duration) {
+				v := min(interval<<(fails-1), limit)
+				return v / 2, v
+			},
+		},
+		{
+			name:    "DecorrelatedJitter",
+			backoff: retrygo.DecorrelatedJitter(interval, limit),
+			bounds: func(fails int) (time.Duration, time.Duration) {
+				return interval, limit
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := retrygo.RetryInfo{Fails: 1}
+			for i := 0; i < 100; i++ {
+				lower, upper := tc.bounds(info.Fails)
+				_, sleep := tc.backoff(info)
+				if sleep < lower || sleep > upper {
+					t.Errorf("fails=%d: expected %s to %s, got %s", info.Fails, lower, upper, sleep)
+				}
+				info.Fails = i%8 + 1
+			}
+		})
+	}
+}
+
+// Test DecorrelatedJitter keeps the previous sleep within a Do call
+func TestDecorrelatedJitterInDo(t *testing.T) {
+	const interval = time.Microsecond
+	const limit = time.Millisecond
+	var sleeps []time.Duration
+	backoff := retrygo.DecorrelatedJitter(interval, limit)
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			_, sleep := backoff(ri)
+			sleeps = append(sleeps, sleep)
+			return ri.Fails < 20, sleep
+		},
+	)
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		return 0, fmt.Errorf("error")
+	})
+
+	previous := interval
+	for _, sleep := range sleeps {
+		if sleep < interval || sleep > min(previous*3, limit) {
+			t.Errorf("expected %s to %s, got %s", interval, min(previous*3, limit), sleep)
+		}
+		previous = sleep
+	}
+}
@@ -1,8 +1,242 @@
 package retrygo
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
 // ErrRecovered is returned when a panic is recovered.
-type ErrRecovered struct{ V any }
+type ErrRecovered struct {
+	V     any    // V is the panic value
+	Stack []byte // Stack is the stack trace of the panic
+}
 
 func (e ErrRecovered) Error() string {
-	return "recovered"
+	return fmt.Sprintf("recovered: %v", e.V)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e ErrRecovered) Unwrap() error {
+	err, _ := e.V.(error)
+	return err
+}
+
+// IsRuntimeError reports whether the panic value is a runtime.Error, such as
+// a nil pointer dereference or an index out of range. Use it with WithRepanic.
+func IsRuntimeError(v any) bool {
+	_, ok := v.(runtime.Error)
+	return ok
+}
+
+// repanic carries a panic that must be re-panicked by Do.
+type repanic struct{ v any }
+
+func (e repanic) Error() string {
+	return fmt.Sprintf("repanic: %v", e.v)
+}
+
+// ErrAttemptTimeout is wrapped by the error of an attempt that exceeded its
+// own timeout while the context of Do was still alive. See WithAttemptTimeout.
+var ErrAttemptTimeout = errors.New("retrygo: attempt timed out")
+
+// ErrResultRejected is the error of an attempt whose result is rejected by a
+// result predicate. See WithRetryOnResult.
+var ErrResultRejected = errors.New("retrygo: result rejected")
+
+// ErrFallbackUsed is wrapped by the error of Do when the result comes from the
+// fallback, even if the fallback fails. See WithFallback.
+var ErrFallbackUsed = errors.New("retrygo: fallback used")
+
+// ErrPermanent is an error that must not be retried. See Permanent.
+type ErrPermanent struct{ Err error }
+
+func (e ErrPermanent) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrPermanent) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as permanent: Do returns it right away, without
+// consulting the RetryPolicy. Permanent returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return ErrPermanent{Err: err}
+}
+
+// ErrRetryAfter is an error that sets the sleep before the next attempt.
+// See RetryAfter.
+type ErrRetryAfter struct {
+	Err   error
+	After time.Duration
+}
+
+func (e ErrRetryAfter) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrRetryAfter) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter marks err with the delay requested for the next attempt, e.g.
+// from a Retry-After header. The RetryPolicy still decides whether to retry,
+// but the sleep becomes exactly d, or at least d with WithRetryAfterMin.
+// RetryAfter returns nil if err is nil.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return ErrRetryAfter{Err: err, After: d}
+}
+
+// StopReason tells why Do stopped retrying.
+type StopReason int
+
+const (
+	StopPolicy    StopReason = iota + 1 // StopPolicy means the RetryPolicy gave up
+	StopContext                         // StopContext means the context is done
+	StopPermanent                       // StopPermanent means the error is not retryable
+	StopDeadline                        // StopDeadline means the next attempt would not end before the context deadline
+)
+
+func (s StopReason) String() string {
+	switch s {
+	case StopPolicy:
+		return "policy gave up"
+	case StopContext:
+		return "context done"
+	case StopPermanent:
+		return "permanent error"
+	case StopDeadline:
+		return "deadline too close"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(s))
+	}
+}
+
+// ErrExhausted is returned by Do when it stops without a success.
+//
+// It unwraps to both the last error returned by the function and, when the
+// context is done, the context error, so errors.Is and errors.As work with
+// either of them.
+type ErrExhausted struct {
+	Reason       StopReason    // Reason is why Do stopped
+	PolicyReason string        // PolicyReason is the reason given by the policy, if any
+	Attempts     int           // Attempts is the number of calls of the function
+	Elapsed      time.Duration // Elapsed is the time since the first attempt
+	Slept        time.Duration // Slept is the total time spent between attempts
+	Err          error         // Err is the last error, nil if there was no attempt
+	Cause        error         // Cause is the context error, if the context is done
+	History      []error       // History is the errors of the earlier attempts, oldest first
+}
+
+func (e *ErrExhausted) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "retrygo: %s", e.Reason)
+	if e.PolicyReason != "" {
+		fmt.Fprintf(&b, " (%s)", e.PolicyReason)
+	}
+	fmt.Fprintf(&b, " after %d attempts", e.Attempts)
+	if e.Cause != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Cause.Error())
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *ErrExhausted) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	return errs
+}
+
+// MatchIs returns a function that reports whether an error matches target
+// (errors.Is).
+func MatchIs(target error) func(error) bool {
+	return func(err error) bool { return errors.Is(err, target) }
+}
+
+// MatchAs returns a function that reports whether an error has an error of
+// type E in its chain (errors.As). E must be an error or an interface type,
+// MatchAs panics otherwise.
+func MatchAs[E any]() func(error) bool {
+	match, err := matchTarget(new(E))
+	if err != nil {
+		panic(err)
+	}
+	return match
+}
+
+// errorStringType is the type of the errors made by errors.New and by
+// fmt.Errorf without %w.
+var errorStringType = reflect.TypeOf(errors.New(""))
+
+// errorClass returns the default class of an error: the type of the innermost
+// error of its chain, with the message if it is made by errors.New or by
+// fmt.Errorf without %w, so that different sentinel errors are different
+// classes. An error that wraps several errors, e.g. with errors.Join, has the
+// classes of all of them.
+func errorClass(err error) string {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return errorClass(inner)
+		}
+	case interface{ Unwrap() []error }:
+		var classes []string
+		for _, inner := range e.Unwrap() {
+			if inner != nil {
+				classes = append(classes, errorClass(inner))
+			}
+		}
+		if len(classes) > 0 {
+			return strings.Join(classes, " + ")
+		}
+	}
+	typ := reflect.TypeOf(err)
+	if typ == errorStringType {
+		return fmt.Sprintf("%s(%q)", typ, err.Error())
+	}
+	return typ.String()
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// matchTarget returns a function that reports whether an error matches the
+// target. The target is either an error, matched with errors.Is, or a non-nil
+// pointer to an error or interface type, matched with errors.As.
+func matchTarget(target any) (func(error) bool, error) {
+	if err, ok := target.(error); ok {
+		return func(e error) bool { return errors.Is(e, err) }, nil
+	}
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Pointer || reflect.ValueOf(target).IsNil() {
+		return nil, fmt.Errorf("retrygo: target must be an error or a non-nil pointer, got %T", target)
+	}
+	elem := typ.Elem()
+	if elem.Kind() != reflect.Interface && !elem.Implements(errorType) {
+		return nil, fmt.Errorf("retrygo: target %T does not point to an error or interface type", target)
+	}
+	return func(e error) bool {
+		// A fresh target for every call keeps the matcher safe for
+		// concurrent use.
+		return errors.As(e, reflect.New(elem).Interface())
+	}, nil
 }
@@ -0,0 +1,158 @@
+package retrygo
+
+import (
+	"expvar"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets of the histograms of ExpvarMetrics.
+var (
+	attemptsBuckets = []float64{1, 2, 3, 5, 10, 20, 50, 100}
+	sleepBuckets    = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60}
+)
+
+// ExpvarMetrics is a Metrics published with the expvar package, e.g. at
+// /debug/vars. The metrics of every Retry are a map with the counters
+// attempts, retries, successes, exhaustions (by stop reason) and recovered,
+// and the histograms attempts_per_call and sleep_seconds.
+//
+// A histogram is a JSON object with the count and the sum of the observed
+// values, and the cumulative count of the values up to every bucket bound.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+
+	mu      sync.Mutex
+	retries map[string]*expvarRetry
+}
+
+// expvarRetry are the metrics of a Retry published by ExpvarMetrics.
+type expvarRetry struct {
+	attempts        expvar.Int
+	retries         expvar.Int
+	successes       expvar.Int
+	exhaustions     expvar.Map
+	recovered       expvar.Int
+	attemptsPerCall *histogram
+	sleep           *histogram
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics published as the expvar variable
+// with the name. Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		vars:    new(expvar.Map),
+		retries: make(map[string]*expvarRetry),
+	}
+	expvar.Publish(name, m.vars)
+	return m
+}
+
+// retry returns the metrics of the Retry with the name, publishing them on
+// first use.
+func (m *ExpvarMetrics) retry(name string) *expvarRetry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.retries[name]; ok {
+		return e
+	}
+	e := &expvarRetry{
+		attemptsPerCall: newHistogram(attemptsBuckets),
+		sleep:           newHistogram(sleepBuckets),
+	}
+	vars := new(expvar.Map)
+	vars.Set("attempts", &e.attempts)
+	vars.Set("retries", &e.retries)
+	vars.Set("successes", &e.successes)
+	vars.Set("exhaustions", &e.exhaustions)
+	vars.Set("recovered", &e.recovered)
+	vars.Set("attempts_per_call", e.attemptsPerCall)
+	vars.Set("sleep_seconds", e.sleep)
+	m.vars.Set(name, vars)
+	m.retries[name] = e
+	return e
+}
+
+func (m *ExpvarMetrics) AddAttempt(name string) {
+	m.retry(name).attempts.Add(1)
+}
+
+func (m *ExpvarMetrics) AddRetry(name string) {
+	m.retry(name).retries.Add(1)
+}
+
+func (m *ExpvarMetrics) AddSuccess(name string) {
+	m.retry(name).successes.Add(1)
+}
+
+func (m *ExpvarMetrics) AddExhausted(name string, reason StopReason) {
+	m.retry(name).exhaustions.Add(reason.String(), 1)
+}
+
+func (m *ExpvarMetrics) AddRecovered(name string) {
+	m.retry(name).recovered.Add(1)
+}
+
+func (m *ExpvarMetrics) ObserveAttempts(name string, attempts int) {
+	m.retry(name).attemptsPerCall.observe(float64(attempts))
+}
+
+func (m *ExpvarMetrics) ObserveSleep(name string, sleep time.Duration) {
+	m.retry(name).sleep.observe(sleep.Seconds())
+}
+
+// histogram is an expvar.Var that counts the observed values by bucket.
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64 // counts has one more bucket for the values above all bounds
+	count  int64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+// String returns the histogram as JSON, see ExpvarMetrics.
+func (h *histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(`{"count": `)
+	b.WriteString(strconv.FormatInt(h.count, 10))
+	b.WriteString(`, "sum": `)
+	b.WriteString(strconv.FormatFloat(h.sum, 'g', -1, 64))
+	b.WriteString(`, "buckets": {`)
+	var cumulative int64
+	for i, n := range h.counts {
+		cumulative += n
+		bound := math.Inf(1)
+		if i < len(h.bounds) {
+			bound = h.bounds[i]
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(strconv.FormatFloat(bound, 'g', -1, 64)))
+		b.WriteString(": ")
+		b.WriteString(strconv.FormatInt(cumulative, 10))
+	}
+	b.WriteString("}}")
+	return b.String()
+}
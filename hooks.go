@@ -0,0 +1,103 @@
+package retrygo
+
+import (
+	"errors"
+	"time"
+)
+
+// hooks are the lifecycle hooks of a Retry. They are called by the retry
+// loop in the goroutine of Do, outside of the panic recovery.
+type hooks struct {
+	onAttempt []func(RetryInfo)
+	onRetry   []func(RetryInfo, time.Duration)
+	onSuccess []func(RetryInfo)
+	onGiveUp  []func(RetryInfo, *ErrExhausted)
+	onRecover []func(RetryInfo)
+}
+
+func (h *hooks) attempt(ri RetryInfo) {
+	for _, hook := range h.onAttempt {
+		hook(ri)
+	}
+}
+
+func (h *hooks) retry(ri RetryInfo, sleep time.Duration) {
+	for _, hook := range h.onRetry {
+		hook(ri, sleep)
+	}
+}
+
+func (h *hooks) success(ri RetryInfo) {
+	for _, hook := range h.onSuccess {
+		hook(ri)
+	}
+}
+
+func (h *hooks) giveUp(ri RetryInfo, err *ErrExhausted) {
+	for _, hook := range h.onGiveUp {
+		hook(ri, err)
+	}
+}
+
+func (h *hooks) recover(ri RetryInfo) {
+	for _, hook := range h.onRecover {
+		hook(ri)
+	}
+}
+
+// WithOnAttempt adds a hook called before every attempt. RetryInfo.Attempt
+// and AttemptStart describe the attempt about to run, the other fields the
+// previous one.
+func WithOnAttempt[T any](hook func(RetryInfo)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if hook == nil {
+				return errors.New("retrygo: nil attempt hook")
+			}
+			r.hooks.onAttempt = append(r.hooks.onAttempt, hook)
+			return nil
+		},
+	}
+}
+
+// WithOnRetry adds a hook called after every failed attempt that is going to
+// be retried, with the sleep planned before the next attempt.
+func WithOnRetry[T any](hook func(RetryInfo, time.Duration)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if hook == nil {
+				return errors.New("retrygo: nil retry hook")
+			}
+			r.hooks.onRetry = append(r.hooks.onRetry, hook)
+			return nil
+		},
+	}
+}
+
+// WithOnSuccess adds a hook called when an attempt succeeds. RetryInfo.Attempt
+// is the number of attempts it took.
+func WithOnSuccess[T any](hook func(RetryInfo)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if hook == nil {
+				return errors.New("retrygo: nil success hook")
+			}
+			r.hooks.onSuccess = append(r.hooks.onSuccess, hook)
+			return nil
+		},
+	}
+}
+
+// WithOnGiveUp adds a hook called when Do stops without a success, with the
+// *ErrExhausted that tells why. It is called before the fallback, if any.
+func WithOnGiveUp[T any](hook func(RetryInfo, *ErrExhausted)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if hook == nil {
+				return errors.New("retrygo: nil give-up hook")
+			}
+			r.hooks.onGiveUp = append(r.hooks.onGiveUp, hook)
+			return nil
+		},
+	}
+}
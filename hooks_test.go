@@ -0,0 +1,132 @@
+package retrygo_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// recorder records the lifecycle hooks of a Retry.
+type recorder struct{ events []string }
+
+func (rec *recorder) options() []retrygo.RetryOption[int] {
+	return []retrygo.RetryOption[int]{
+		retrygo.WithOnAttempt[int](func(ri retrygo.RetryInfo) {
+			rec.events = append(rec.events, fmt.Sprintf("attempt %d", ri.Attempt))
+		}),
+		retrygo.WithOnRetry[int](func(ri retrygo.RetryInfo, sleep time.Duration) {
+			rec.events = append(rec.events, fmt.Sprintf("retry %d %s %s", ri.Fails, ri.Err, sleep))
+		}),
+		retrygo.WithOnSuccess[int](func(ri retrygo.RetryInfo) {
+			rec.events = append(rec.events, fmt.Sprintf("success %d", ri.Attempt))
+		}),
+		retrygo.WithOnGiveUp[int](func(ri retrygo.RetryInfo, err *retrygo.ErrExhausted) {
+			rec.events = append(rec.events, fmt.Sprintf("give up %d %s (%s)", ri.Attempt, err.Reason, err.PolicyReason))
+		}),
+	}
+}
+
+// Test the lifecycle hooks
+func TestHooks(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		recovery bool
+		fail     func(calls int) (int, error)
+		expected string
+	}{
+		{
+			name: "Success",
+			fail: func(calls int) (int, error) {
+				if calls < 3 {
+					return 0, fmt.Errorf("error %d", calls)
+				}
+				return calls, nil
+			},
+			expected: "attempt 1, retry 1 error 1 1ms, attempt 2, retry 2 error 2 2ms, attempt 3, success 3",
+		},
+		{
+			name: "GiveUp",
+			fail: func(calls int) (int, error) {
+				return 0, fmt.Errorf("error %d", calls)
+			},
+			expected: "attempt 1, retry 1 error 1 1ms, attempt 2, retry 2 error 2 2ms, attempt 3, give up 3 policy gave up (count limit 3 reached)",
+		},
+		{
+			name:     "Recovery",
+			recovery: true,
+			fail: func(calls int) (int, error) {
+				if calls < 3 {
+					panic(fmt.Sprintf("panic %d", calls))
+				}
+				return calls, nil
+			},
+			expected: "attempt 1, retry 1 recovered: panic 1 1ms, attempt 2, retry 2 recovered: panic 2 2ms, attempt 3, success 3",
+		},
+		{
+			name:     "RecoveryGiveUp",
+			recovery: true,
+			fail: func(calls int) (int, error) {
+				panic(fmt.Sprintf("panic %d", calls))
+			},
+			expected: "attempt 1, retry 1 recovered: panic 1 1ms, attempt 2, retry 2 recovered: panic 2 2ms, attempt 3, give up 3 policy gave up (count limit 3 reached)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &recorder{}
+			options := rec.options()
+			if tc.recovery {
+				options = append(options, retrygo.WithRecovery[int]())
+			}
+			retry, _ := retrygo.New[int](
+				retrygo.Combine(retrygo.LimitCount(3), retrygo.Linear(time.Millisecond)),
+				options...,
+			)
+			calls := 0
+			_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+				calls++
+				return tc.fail(calls)
+			})
+			if actual := strings.Join(rec.events, ", "); actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// Test the give-up hook on context cancellation
+func TestHooksContext(t *testing.T) {
+	rec := &recorder{}
+	retry, _ := retrygo.New[int](retrygo.Constant(time.Hour), rec.options()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = retry.Do(ctx, func(context.Context) (int, error) {
+		return 0, nil
+	})
+	if actual := strings.Join(rec.events, ", "); actual != "give up 0 context done ()" {
+		t.Errorf("unexpected events %s", actual)
+	}
+}
+
+// Test that the success hook gets the returned result
+func TestHooksSuccessResult(t *testing.T) {
+	var seen any
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(3),
+		retrygo.WithOnSuccess[int](func(ri retrygo.RetryInfo) { seen = ri.Result }),
+	)
+	calls := 0
+	val, _ := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 111, fmt.Errorf("error")
+		}
+		return 222, nil
+	})
+	if val != 222 || seen != 222 {
+		t.Errorf("expected the hook to see 222, got %v (returned %d)", seen, val)
+	}
+}
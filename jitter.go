@@ -0,0 +1,64 @@
+package retrygo
+
+import (
+	"time"
+)
+
+// JitterDist randomizes a sleep with the random source r. It is used by
+// WithJitter.
+type JitterDist func(r Rand, sleep time.Duration) time.Duration
+
+// UniformDist returns a JitterDist that spreads the sleep uniformly by up to
+// fraction of it in both directions.
+//
+// Sleep formula: sleep * (1 + rand(-fraction, fraction))
+func UniformDist(fraction float64) JitterDist {
+	return func(r Rand, sleep time.Duration) time.Duration {
+		return saturate(float64(sleep) * (1 + fraction*(2*r.Float64()-1)))
+	}
+}
+
+// FullDist returns a JitterDist that picks the sleep uniformly between zero
+// and the sleep.
+//
+// Sleep formula: rand(0, sleep)
+func FullDist() JitterDist {
+	return randDuration
+}
+
+// GaussianDist returns a JitterDist that picks the sleep from the normal
+// distribution centered on the sleep, with a standard deviation of stddev
+// times the sleep.
+//
+// Sleep formula: sleep * (1 + stddev * N(0, 1))
+func GaussianDist(stddev float64) JitterDist {
+	return func(r Rand, sleep time.Duration) time.Duration {
+		return saturate(float64(sleep) * (1 + stddev*r.NormFloat64()))
+	}
+}
+
+// ExponentialDist returns a JitterDist that picks the sleep from the
+// exponential distribution whose mean is the sleep.
+//
+// Sleep formula: sleep * Exp(1)
+func ExponentialDist() JitterDist {
+	return func(r Rand, sleep time.Duration) time.Duration {
+		return saturate(float64(sleep) * r.ExpFloat64())
+	}
+}
+
+// WithJitter returns a RetryPolicy that randomizes the sleep of the policy
+// with the distribution, using the random source of the Do call. The continue
+// decision is passed through unchanged, the result is never negative and a
+// zero sleep stays zero.
+//
+// Sleep formula: dist(sleep)
+func WithJitter(policy RetryPolicy, dist JitterDist) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		continueRetry, sleep := policy(ri)
+		if sleep <= 0 {
+			return continueRetry, 0
+		}
+		return continueRetry, max(dist(ri.random(), sleep), 0)
+	}
+}
@@ -0,0 +1,51 @@
+package retrygo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// Test WithJitter
+func TestWithJitter(t *testing.T) {
+	const interval = time.Second
+	for _, tc := range []struct {
+		name  string
+		dist  retrygo.JitterDist
+		lower time.Duration
+		upper time.Duration
+	}{
+		{"Uniform", retrygo.UniformDist(0.25), 750 * time.Millisecond, 1250 * time.Millisecond},
+		{"UniformWide", retrygo.UniformDist(2), 0, 3 * time.Second},
+		{"Full", retrygo.FullDist(), 0, interval},
+		{"Gaussian", retrygo.GaussianDist(1), 0, time.Hour},
+		{"Exponential", retrygo.ExponentialDist(), 0, time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backoff := retrygo.WithJitter(retrygo.Constant(interval), tc.dist)
+			for i := 0; i < 1000; i++ {
+				continueRetry, sleep := backoff(retrygo.RetryInfo{Fails: 1})
+				if !continueRetry {
+					t.Fatal("expected the continue decision to pass through")
+				}
+				if sleep < tc.lower || sleep > tc.upper {
+					t.Fatalf("expected %s to %s, got %s", tc.lower, tc.upper, sleep)
+				}
+			}
+
+			// A zero sleep stays zero and the stop decision passes through.
+			backoff = retrygo.WithJitter(retrygo.LimitCount(1), tc.dist)
+			if continueRetry, sleep := backoff(retrygo.RetryInfo{Fails: 1}); continueRetry || sleep != 0 {
+				t.Errorf("expected false and 0, got %t and %s", continueRetry, sleep)
+			}
+		})
+	}
+}
+
+// Test Jitter with a zero interval
+func TestJitterZero(t *testing.T) {
+	if _, sleep := retrygo.Jitter(0)(retrygo.RetryInfo{Fails: 1}); sleep != 0 {
+		t.Errorf("expected 0, got %s", sleep)
+	}
+}
@@ -0,0 +1,130 @@
+package retrygo
+
+import (
+	"errors"
+	"log/slog"
+	"math/bits"
+	"time"
+)
+
+// LogOptions sets how WithLogger logs.
+type LogOptions struct {
+	RetryLevel  slog.Leveler // RetryLevel is the level of the retries, slog.LevelInfo if nil
+	GiveUpLevel slog.Leveler // GiveUpLevel is the level of the give-ups, slog.LevelWarn if nil
+
+	// Sample reports whether to log a retry after the n-th occurrence of the
+	// same error message in a Do call. If nil, the retries are logged after
+	// the 1st, 2nd, 4th, 8th... occurrence, so a tight retry loop can't flood
+	// the logs. The give-ups are always logged.
+	Sample func(n int) bool
+}
+
+// WithLogger logs every retry and every give-up with the logger. The records
+// have the attributes:
+//   - retry: the name of the Retry, see WithName
+//   - attempt: the number of the last attempt
+//   - fails: the number of failures
+//   - error: the last error, if any
+//   - elapsed: the time since the first attempt
+//   - sleep: the sleep before the next attempt (retries only)
+//   - repeats: the occurrences of the error message so far (retries only)
+//   - reason: the stop reason (give-ups only)
+//   - policy_reason: the reason given by the policy, if any (give-ups only)
+//   - cause: the context error, if the context is done (give-ups only)
+func WithLogger[T any](logger *slog.Logger, opts LogOptions) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if logger == nil {
+				return errors.New("retrygo: nil logger")
+			}
+			l := retryLogger{
+				logger:      logger,
+				retryLevel:  opts.RetryLevel,
+				giveUpLevel: opts.GiveUpLevel,
+				sample:      opts.Sample,
+			}
+			if l.retryLevel == nil {
+				l.retryLevel = slog.LevelInfo
+			}
+			if l.giveUpLevel == nil {
+				l.giveUpLevel = slog.LevelWarn
+			}
+			if l.sample == nil {
+				l.sample = powerOfTwo
+			}
+			r.hooks.onRetry = append(r.hooks.onRetry, l.retry)
+			r.hooks.onGiveUp = append(r.hooks.onGiveUp, l.giveUp)
+			return nil
+		},
+	}
+}
+
+// powerOfTwo reports whether n is a power of two.
+func powerOfTwo(n int) bool {
+	return n > 0 && bits.OnesCount(uint(n)) == 1
+}
+
+// retryLogger logs the retries and the give-ups of a Retry.
+type retryLogger struct {
+	logger      *slog.Logger
+	retryLevel  slog.Leveler
+	giveUpLevel slog.Leveler
+	sample      func(n int) bool
+}
+
+func (l retryLogger) retry(ri RetryInfo, sleep time.Duration) {
+	ctx, level := ri.call.context(), l.retryLevel.Level()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	repeats := ri.call.repeat(ri.Err)
+	if !l.sample(repeats) {
+		return
+	}
+	attrs := append(l.attrs(ri, ri.Err),
+		slog.Duration("sleep", sleep),
+		slog.Int("repeats", repeats),
+	)
+	l.logger.LogAttrs(ctx, level, "retrygo: retrying after error", attrs...)
+}
+
+func (l retryLogger) giveUp(ri RetryInfo, err *ErrExhausted) {
+	ctx, level := ri.call.context(), l.giveUpLevel.Level()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := append(l.attrs(ri, err.Err), slog.String("reason", err.Reason.String()))
+	if err.PolicyReason != "" {
+		attrs = append(attrs, slog.String("policy_reason", err.PolicyReason))
+	}
+	if err.Cause != nil {
+		attrs = append(attrs, slog.Any("cause", err.Cause))
+	}
+	l.logger.LogAttrs(ctx, level, "retrygo: giving up", attrs...)
+}
+
+// attrs returns the attributes common to all the records.
+func (l retryLogger) attrs(ri RetryInfo, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("retry", ri.call.retryName()),
+		slog.Int("attempt", ri.Attempt),
+		slog.Int("fails", ri.Fails),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	return append(attrs, slog.Duration("elapsed", time.Since(ri.Since)))
+}
+
+// repeat counts one more occurrence of the error message and returns the
+// occurrences so far.
+func (c *callState) repeat(err error) int {
+	if c == nil || err == nil {
+		return 1
+	}
+	if c.errors == nil {
+		c.errors = make(map[string]int)
+	}
+	c.errors[err.Error()]++
+	return c.errors[err.Error()]
+}
@@ -0,0 +1,106 @@
+package retrygo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// newTestLogger returns a logger that writes to buf without the varying
+// attributes.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "elapsed" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// Test the records of WithLogger
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	retry, err := retrygo.New[int](
+		retrygo.Combine(retrygo.LimitCount(5), retrygo.Constant(time.Millisecond)),
+		retrygo.WithName[int]("fetch"),
+		retrygo.WithLogger[int](newTestLogger(&buf), retrygo.LogOptions{GiveUpLevel: slog.LevelError}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		return 0, errors.New("boom")
+	})
+	requiredValues := []string{
+		`level=INFO msg="retrygo: retrying after error" retry=fetch attempt=1 fails=1 error=boom sleep=1ms repeats=1`,
+		`level=INFO msg="retrygo: retrying after error" retry=fetch attempt=2 fails=2 error=boom sleep=1ms repeats=2`,
+		`level=INFO msg="retrygo: retrying after error" retry=fetch attempt=4 fails=4 error=boom sleep=1ms repeats=4`,
+		`level=ERROR msg="retrygo: giving up" retry=fetch attempt=5 fails=5 error=boom reason="policy gave up" policy_reason="count limit 5 reached"`,
+	}
+	actual := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(actual) != len(requiredValues) {
+		t.Fatalf("expected %d records, got %d:\n%s", len(requiredValues), len(actual), buf.String())
+	}
+	for i, required := range requiredValues {
+		if actual[i] != required {
+			t.Errorf("expected %s, got %s", required, actual[i])
+		}
+	}
+}
+
+// Test the sampling and the levels of WithLogger
+func TestLoggerOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     retrygo.LogOptions
+		expected int
+	}{
+		{name: "Default", opts: retrygo.LogOptions{}, expected: 4},
+		{name: "NoSampling", opts: retrygo.LogOptions{Sample: func(int) bool { return true }}, expected: 8},
+		{name: "GiveUpOnly", opts: retrygo.LogOptions{RetryLevel: slog.LevelDebug - 1}, expected: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			retry, _ := retrygo.New[int](
+				retrygo.LimitCount(8),
+				retrygo.WithLogger[int](newTestLogger(&buf), tc.opts),
+			)
+			_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+				return 0, errors.New("boom")
+			})
+			if actual := strings.Count(buf.String(), "\n"); actual != tc.expected {
+				t.Errorf("expected %d records, got %d:\n%s", tc.expected, actual, buf.String())
+			}
+		})
+	}
+}
+
+// Test the give-up record on context cancellation
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	retry, _ := retrygo.New[int](
+		retrygo.Constant(time.Hour),
+		retrygo.WithLogger[int](newTestLogger(&buf), retrygo.LogOptions{}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = retry.Do(ctx, func(context.Context) (int, error) {
+		return 0, nil
+	})
+	expected := `level=WARN msg="retrygo: giving up" retry="" attempt=0 fails=0 reason="context done" cause="context canceled"` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+	if _, err := retrygo.New[int](retrygo.Constant(0), retrygo.WithLogger[int](nil, retrygo.LogOptions{})); err == nil {
+		t.Error("expected an error for a nil logger")
+	}
+}
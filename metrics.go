@@ -0,0 +1,148 @@
+package retrygo
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Metrics collects the metrics of the Retry instances, labeled by the name
+// set with WithName. It must be safe for concurrent use. See WithMetrics.
+//
+// Metrics has no dependencies, so an adapter to Prometheus or another
+// metrics library is a few lines of code.
+type Metrics interface {
+	// AddAttempt counts an attempt.
+	AddAttempt(name string)
+	// AddRetry counts a failed attempt that is going to be retried.
+	AddRetry(name string)
+	// AddSuccess counts a Do call that succeeded.
+	AddSuccess(name string)
+	// AddExhausted counts a Do call that stopped without a success.
+	AddExhausted(name string, reason StopReason)
+	// AddRecovered counts a panic recovered in the recovery mode.
+	AddRecovered(name string)
+	// ObserveAttempts observes the number of attempts of a Do call.
+	ObserveAttempts(name string, attempts int)
+	// ObserveSleep observes the sleep planned before a retry.
+	ObserveSleep(name string, sleep time.Duration)
+}
+
+// WithMetrics reports the attempts, the retries and the outcome of every Do
+// call to the metrics.
+func WithMetrics[T any](metrics Metrics) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if metrics == nil {
+				return errors.New("retrygo: nil metrics")
+			}
+			r.hooks.onAttempt = append(r.hooks.onAttempt, func(ri RetryInfo) {
+				metrics.AddAttempt(ri.call.retryName())
+			})
+			r.hooks.onRetry = append(r.hooks.onRetry, func(ri RetryInfo, sleep time.Duration) {
+				name := ri.call.retryName()
+				metrics.AddRetry(name)
+				metrics.ObserveSleep(name, sleep)
+			})
+			r.hooks.onSuccess = append(r.hooks.onSuccess, func(ri RetryInfo) {
+				name := ri.call.retryName()
+				metrics.AddSuccess(name)
+				metrics.ObserveAttempts(name, ri.Attempt)
+			})
+			r.hooks.onGiveUp = append(r.hooks.onGiveUp, func(ri RetryInfo, err *ErrExhausted) {
+				name := ri.call.retryName()
+				metrics.AddExhausted(name, err.Reason)
+				metrics.ObserveAttempts(name, ri.Attempt)
+			})
+			r.hooks.onRecover = append(r.hooks.onRecover, func(ri RetryInfo) {
+				metrics.AddRecovered(ri.call.retryName())
+			})
+			return nil
+		},
+	}
+}
+
+// MetricsStats are the metrics of a Retry collected by MemoryMetrics.
+type MetricsStats struct {
+	Attempts    int64                // Attempts is the number of attempts
+	Retries     int64                // Retries is the number of retries
+	Successes   int64                // Successes is the number of Do calls that succeeded
+	Exhaustions map[StopReason]int64 // Exhaustions is the number of Do calls that stopped without a success, by reason
+	Recovered   int64                // Recovered is the number of panics recovered
+
+	AttemptsPerCall []int           // AttemptsPerCall is the number of attempts of every Do call
+	Sleeps          []time.Duration // Sleeps is the sleep planned before every retry
+}
+
+// MemoryMetrics is a Metrics that keeps everything in memory, e.g. for tests.
+// The zero value is ready to use.
+type MemoryMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*MetricsStats
+}
+
+// NewMemoryMetrics returns an empty MemoryMetrics.
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{}
+}
+
+// Stats returns a copy of the metrics of the Retry with the name.
+func (m *MemoryMetrics) Stats(name string) MetricsStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.stats[name]
+	if !ok {
+		return MetricsStats{}
+	}
+	stats := *s
+	stats.Exhaustions = make(map[StopReason]int64, len(s.Exhaustions))
+	for reason, n := range s.Exhaustions {
+		stats.Exhaustions[reason] = n
+	}
+	stats.AttemptsPerCall = append([]int(nil), s.AttemptsPerCall...)
+	stats.Sleeps = append([]time.Duration(nil), s.Sleeps...)
+	return stats
+}
+
+// update calls f with the metrics of the Retry with the name.
+func (m *MemoryMetrics) update(name string, f func(*MetricsStats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats == nil {
+		m.stats = make(map[string]*MetricsStats)
+	}
+	s, ok := m.stats[name]
+	if !ok {
+		s = &MetricsStats{Exhaustions: make(map[StopReason]int64)}
+		m.stats[name] = s
+	}
+	f(s)
+}
+
+func (m *MemoryMetrics) AddAttempt(name string) {
+	m.update(name, func(s *MetricsStats) { s.Attempts++ })
+}
+
+func (m *MemoryMetrics) AddRetry(name string) {
+	m.update(name, func(s *MetricsStats) { s.Retries++ })
+}
+
+func (m *MemoryMetrics) AddSuccess(name string) {
+	m.update(name, func(s *MetricsStats) { s.Successes++ })
+}
+
+func (m *MemoryMetrics) AddExhausted(name string, reason StopReason) {
+	m.update(name, func(s *MetricsStats) { s.Exhaustions[reason]++ })
+}
+
+func (m *MemoryMetrics) AddRecovered(name string) {
+	m.update(name, func(s *MetricsStats) { s.Recovered++ })
+}
+
+func (m *MemoryMetrics) ObserveAttempts(name string, attempts int) {
+	m.update(name, func(s *MetricsStats) { s.AttemptsPerCall = append(s.AttemptsPerCall, attempts) })
+}
+
+func (m *MemoryMetrics) ObserveSleep(name string, sleep time.Duration) {
+	m.update(name, func(s *MetricsStats) { s.Sleeps = append(s.Sleeps, sleep) })
+}
@@ -0,0 +1,93 @@
+package retrygo_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// runMetrics runs a successful call with two recovered panics and a call that
+// gives up.
+func runMetrics(t *testing.T, metrics retrygo.Metrics) {
+	t.Helper()
+	retry, err := retrygo.New[int](
+		retrygo.Combine(retrygo.LimitCount(3), retrygo.Linear(time.Millisecond)),
+		retrygo.WithName[int]("fetch"),
+		retrygo.WithRecovery[int](),
+		retrygo.WithMetrics[int](metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			panic("boom")
+		}
+		return calls, nil
+	})
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		return 0, errors.New("boom")
+	})
+}
+
+// Test MemoryMetrics with WithMetrics
+func TestMemoryMetrics(t *testing.T) {
+	metrics := retrygo.NewMemoryMetrics()
+	runMetrics(t, metrics)
+	expected := retrygo.MetricsStats{
+		Attempts:        6,
+		Retries:         4,
+		Successes:       1,
+		Exhaustions:     map[retrygo.StopReason]int64{retrygo.StopPolicy: 1},
+		Recovered:       2,
+		AttemptsPerCall: []int{3, 3},
+		Sleeps:          []time.Duration{time.Millisecond, 2 * time.Millisecond, time.Millisecond, 2 * time.Millisecond},
+	}
+	if actual := metrics.Stats("fetch"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if actual := metrics.Stats("other"); actual.Attempts != 0 {
+		t.Errorf("expected no metrics, got %+v", actual)
+	}
+	if _, err := retrygo.New[int](retrygo.Constant(0), retrygo.WithMetrics[int](nil)); err == nil {
+		t.Error("expected an error for nil metrics")
+	}
+}
+
+// expvarRuns numbers the runs of TestExpvarMetrics, an expvar name can't be
+// published twice.
+var expvarRuns atomic.Int64
+
+// Test ExpvarMetrics with WithMetrics
+func TestExpvarMetrics(t *testing.T) {
+	name := fmt.Sprintf("retrygo_test_%d", expvarRuns.Add(1))
+	runMetrics(t, retrygo.NewExpvarMetrics(name))
+	var vars map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	requiredValues := map[string]string{
+		"attempts":          `6`,
+		"retries":           `4`,
+		"successes":         `1`,
+		"exhaustions":       `{"policy gave up": 1}`,
+		"recovered":         `2`,
+		"attempts_per_call": `{"count": 2, "sum": 6, "buckets": {"1": 0, "2": 0, "3": 2, "5": 2, "10": 2, "20": 2, "50": 2, "100": 2, "+Inf": 2}}`,
+		"sleep_seconds":     `{"count": 4, "sum": 0.006, "buckets": {"0.001": 2, "0.01": 4, "0.1": 4, "0.5": 4, "1": 4, "5": 4, "10": 4, "30": 4, "60": 4, "+Inf": 4}}`,
+	}
+	for key, required := range requiredValues {
+		if actual := fmt.Sprintf("%s", vars["fetch"][key]); actual != required {
+			t.Errorf("expected %s = %s, got %s", key, required, actual)
+		}
+	}
+}
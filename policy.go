@@ -1,26 +1,191 @@
 package retrygo
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
 // RetryInfo contains information about the retry
 type RetryInfo struct {
-	Fails int       // Fails is the number of retries
-	Err   error     // Err is the error returned by the function
-	Since time.Time // Since is the time when the retry started
+	Fails  int       // Fails is the number of retries
+	Err    error     // Err is the error returned by the function, or ErrResultRejected
+	Since  time.Time // Since is the time when the retry started
+	Result any       // Result is the result returned by the function
+
+	Attempt         int           // Attempt is the number of the last attempt, starting from 1
+	AttemptStart    time.Time     // AttemptStart is the time when the last attempt started
+	AttemptDuration time.Duration // AttemptDuration is how long the last attempt took
+	LastSleep       time.Duration // LastSleep is the sleep before the last attempt
+	TotalSleep      time.Duration // TotalSleep is the sum of all the sleeps so far
+
+	Class       string // Class is the class of Err, see WithErrorClass
+	ClassFails  int    // ClassFails is the number of retries with an error of the same class
+	Consecutive int    // Consecutive is the number of the last retries in a row with an error of the same class
+
+	call *callState // call is the state of the Do call, nil outside of Do
+}
+
+// callState is the state shared by the policies and the hooks of a single Do
+// call.
+type callState struct {
+	ctx    context.Context
+	name   string
+	states map[*statefulKey]RetryPolicy
+	rand   Rand
+	errors map[string]int // errors counts the error messages, see WithLogger
+
+	// running is closed when the last abandoned attempt returns, nil if
+	// there is none, see WithAbandonOnCancel.
+	running chan struct{}
+
+	// reason and attemptTimeout are the details of the current decision.
+	reason         string
+	attemptTimeout time.Duration
+}
+
+// record keeps the details of a policy decision. The first reason to stop
+// wins, and so does the shortest attempt timeout.
+func (c *callState) record(d Decision) {
+	if c == nil {
+		return
+	}
+	if !d.Continue && c.reason == "" {
+		c.reason = d.Reason
+	}
+	if d.AttemptTimeout > 0 && (c.attemptTimeout == 0 || d.AttemptTimeout < c.attemptTimeout) {
+		c.attemptTimeout = d.AttemptTimeout
+	}
+}
+
+// context returns the context of the Do call.
+func (c *callState) context() context.Context {
+	if c == nil || c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
+// retryName returns the name of the Retry.
+func (c *callState) retryName() string {
+	if c == nil {
+		return ""
+	}
+	return c.name
+}
+
+// statefulKey identifies a Stateful policy. It is not zero-sized, so every
+// allocation has its own address.
+type statefulKey struct{ _ byte }
+
 // RetryPolicy is a function that returns a retry strategy based on the RetryInfo
 type RetryPolicy func(RetryInfo) (continueRetry bool, sleep time.Duration)
 
+// Decision is a detailed retry decision.
+type Decision struct {
+	Continue       bool          // Continue tells whether to retry
+	Sleep          time.Duration // Sleep is the time to wait before the next attempt
+	Reason         string        // Reason explains why retrying stops
+	AttemptTimeout time.Duration // AttemptTimeout limits the next attempt, 0 means no limit
+}
+
+// DecisionPolicy is a function that returns a detailed retry decision based
+// on the RetryInfo. Use FromDecision to turn it into a RetryPolicy.
+type DecisionPolicy func(RetryInfo) Decision
+
+// FromDecision returns a RetryPolicy that makes the decisions of the policy.
+// The reason and the attempt timeout of a decision reach Do even when the
+// returned RetryPolicy is wrapped by Combine or other policies.
+func FromDecision(policy DecisionPolicy) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		d := policy(ri)
+		ri.call.record(d)
+		return d.Continue, d.Sleep
+	}
+}
+
+// Decide calls the policy and returns its decision, including the details
+// recorded by the policies made with FromDecision.
+func (p RetryPolicy) Decide(ri RetryInfo) Decision {
+	if ri.call == nil {
+		ri.call = &callState{}
+	}
+	reason, attemptTimeout := ri.call.reason, ri.call.attemptTimeout
+	ri.call.reason, ri.call.attemptTimeout = "", 0
+	continueRetry, sleep := p(ri)
+	d := Decision{
+		Continue:       continueRetry,
+		Sleep:          sleep,
+		Reason:         ri.call.reason,
+		AttemptTimeout: ri.call.attemptTimeout,
+	}
+	ri.call.reason, ri.call.attemptTimeout = reason, attemptTimeout
+	return d
+}
+
+// Policy creates the retry strategy of a single Do call. Stateful strategies,
+// e.g. the ones that remember the previous sleep, implement Policy so that
+// every Do call gets its own state, even when a Retry is shared by many
+// goroutines.
+//
+// RetryPolicy implements Policy. Use Stateful to turn a Policy into a
+// RetryPolicy.
+type Policy interface {
+	// NewAttemptState returns the RetryPolicy used for one Do call.
+	NewAttemptState() RetryPolicy
+}
+
+// NewAttemptState returns the policy itself, a RetryPolicy keeps no state.
+func (p RetryPolicy) NewAttemptState() RetryPolicy {
+	return p
+}
+
+// PolicyFunc is a type adapter that turns a factory function into a Policy.
+type PolicyFunc func() RetryPolicy
+
+// NewAttemptState calls the factory function.
+func (f PolicyFunc) NewAttemptState() RetryPolicy {
+	return f()
+}
+
+// Stateful returns a RetryPolicy that creates a fresh state of the policy on
+// the first retry of every Do call and uses it until the call returns.
+// Stateful policies compose with Combine and the other policies like any
+// RetryPolicy.
+//
+// Outside of Do, e.g. when the returned RetryPolicy is called directly, every
+// call gets a fresh state.
+//
+// The state belongs to the returned RetryPolicy, not to the place where it is
+// used: the same RetryPolicy used twice in one policy tree, e.g. p in
+// Combine(p, p), shares one state and updates it twice per failure. Call
+// Stateful, or the constructor of a stateful policy, once per use instead.
+func Stateful(policy Policy) RetryPolicy {
+	key := new(statefulKey)
+	return func(ri RetryInfo) (bool, time.Duration) {
+		if ri.call == nil {
+			return policy.NewAttemptState()(ri)
+		}
+		state, ok := ri.call.states[key]
+		if !ok {
+			if ri.call.states == nil {
+				ri.call.states = make(map[*statefulKey]RetryPolicy)
+			}
+			state = policy.NewAttemptState()
+			ri.call.states[key] = state
+		}
+		return state(ri)
+	}
+}
+
 // LimitCount returns a RetryPolicy that limits the number of retries.
 //
 // Sleep formula: 0
 func LimitCount(count int) RetryPolicy {
-	return func(ri RetryInfo) (bool, time.Duration) {
-		return ri.Fails < count, 0
-	}
+	reason := fmt.Sprintf("count limit %d reached", count)
+	return FromDecision(func(ri RetryInfo) Decision {
+		return Decision{Continue: ri.Fails < count, Reason: reason}
+	})
 }
 
 // LimitTime returns a RetryPolicy that limits the total time spent on retries.
@@ -29,26 +194,187 @@ func LimitCount(count int) RetryPolicy {
 //
 // WARNING: Use context.WithTimeout instead of this function if you can!
 func LimitTime(limit time.Duration) RetryPolicy {
-	return func(ri RetryInfo) (bool, time.Duration) {
-		return time.Since(ri.Since) < limit, 0
-	}
+	reason := fmt.Sprintf("time limit %s reached", limit)
+	return FromDecision(func(ri RetryInfo) Decision {
+		return Decision{Continue: time.Since(ri.Since) < limit, Reason: reason}
+	})
+}
+
+// LimitConsecutive returns a RetryPolicy that limits the number of retries in
+// a row with an error that matches. Any other error resets the count.
+// The returned policy is stateful, don't use it twice in a policy tree, see
+// Stateful.
+//
+// Sleep formula: 0
+func LimitConsecutive(match func(error) bool, count int) RetryPolicy {
+	reason := fmt.Sprintf("consecutive limit %d reached", count)
+	return Stateful(PolicyFunc(func() RetryPolicy {
+		consecutive := 0
+		return FromDecision(func(ri RetryInfo) Decision {
+			if match(ri.Err) {
+				consecutive++
+			} else {
+				consecutive = 0
+			}
+			return Decision{Continue: consecutive < count, Reason: reason}
+		})
+	}))
+}
+
+// ResetOnErrorChange returns a RetryPolicy that restarts the policy when the
+// class of the error changes: the policy sees RetryInfo.Fails as the number of
+// the last retries in a row with an error of the same class, see
+// WithErrorClass. The stateful policies it wraps, e.g. Switch or
+// DecorrelatedJitter, also start over with a fresh state.
+//
+// Sleep formula: sleep of the policy
+func ResetOnErrorChange(policy RetryPolicy) RetryPolicy {
+	return Stateful(PolicyFunc(func() RetryPolicy {
+		// states are the states of the wrapped policies since the last change
+		// of the error class.
+		var states map[*statefulKey]RetryPolicy
+		return func(ri RetryInfo) (bool, time.Duration) {
+			if ri.Consecutive > 0 {
+				ri.Fails = ri.Consecutive
+			}
+			if ri.Consecutive == 1 {
+				states = nil
+			}
+			if ri.call != nil {
+				outer := ri.call.states
+				ri.call.states = states
+				defer func() {
+					states, ri.call.states = ri.call.states, outer
+				}()
+			}
+			return policy(ri)
+		}
+	}))
 }
 
 // Combine returns a RetryPolicy that combines multiple RetryPolicies.
 // The function will return true if all the policies return true. (logical AND)
 // If all the policies return true, the function will return the sum of the sleep durations.
+// If a policy gives up without a reason, Combine reports its position.
+// Use Stateful to combine a Policy with RetryPolicies.
 //
 // Sleep formula: sleep1 + sleep2 + ...
 func Combine(policies ...RetryPolicy) RetryPolicy {
+	return all("Combine", policies, func(a, b time.Duration) time.Duration { return a + b })
+}
+
+// MaxSleep returns a RetryPolicy that returns true if all the policies return
+// true (logical AND), with the longest of their sleep durations.
+//
+// Sleep formula: max(sleep1, sleep2, ...)
+func MaxSleep(policies ...RetryPolicy) RetryPolicy {
+	return all("MaxSleep", policies, func(a, b time.Duration) time.Duration { return max(a, b) })
+}
+
+// MinSleep returns a RetryPolicy that returns true if all the policies return
+// true (logical AND), with the shortest of their sleep durations.
+//
+// Sleep formula: min(sleep1, sleep2, ...)
+func MinSleep(policies ...RetryPolicy) RetryPolicy {
+	return all("MinSleep", policies, func(a, b time.Duration) time.Duration { return min(a, b) })
+}
+
+// all returns a RetryPolicy that returns true if all the policies return true,
+// with their sleep durations reduced by reduce.
+func all(name string, policies []RetryPolicy, reduce func(a, b time.Duration) time.Duration) RetryPolicy {
 	return func(ri RetryInfo) (bool, time.Duration) {
-		comulativeSleep := 0 * time.Second
-		for _, policy := range policies {
+		var result time.Duration
+		for i, policy := range policies {
 			continueRetry, sleep := policy(ri)
 			if !continueRetry {
+				ri.call.record(Decision{Reason: fmt.Sprintf("policy %d of %s gave up", i+1, name)})
 				return false, 0
 			}
-			comulativeSleep += sleep
+			if i == 0 {
+				result = sleep
+			} else {
+				result = reduce(result, sleep)
+			}
+		}
+		return true, result
+	}
+}
+
+// Any returns a RetryPolicy that returns true if at least one of the policies
+// returns true (logical OR), with the sum of the sleep durations of the
+// policies that return true. All the policies are called. If they all give
+// up, Any reports the reason of the first one.
+//
+// Sleep formula: sum of sleepN for the policies that continue
+func Any(policies ...RetryPolicy) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		continueAny := false
+		comulativeSleep := 0 * time.Second
+		var stop Decision
+		for _, policy := range policies {
+			d := policy.Decide(ri)
+			if d.Continue {
+				continueAny = true
+				comulativeSleep += d.Sleep
+				ri.call.record(d)
+			} else if stop.Reason == "" {
+				stop = d
+			}
+		}
+		if !continueAny {
+			ri.call.record(stop)
+			ri.call.record(Decision{Reason: "all policies of Any gave up"})
+			return false, 0
 		}
 		return true, comulativeSleep
 	}
 }
+
+// First returns a RetryPolicy that returns the decision of the first policy
+// that returns true. The policies after it are not called. If they all give
+// up, First reports the reason of the first one.
+//
+// Sleep formula: sleepN of the first policy that continues
+func First(policies ...RetryPolicy) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		var stop Decision
+		for _, policy := range policies {
+			d := policy.Decide(ri)
+			if d.Continue {
+				ri.call.record(d)
+				return true, d.Sleep
+			}
+			if stop.Reason == "" {
+				stop = d
+			}
+		}
+		ri.call.record(stop)
+		ri.call.record(Decision{Reason: "all policies of First gave up"})
+		return false, 0
+	}
+}
+
+// Stage is a step of Sequence: Policy is used for the next Fails failures.
+type Stage struct {
+	Fails  int         // Fails is the number of failures of the stage, unlimited if not positive
+	Policy RetryPolicy // Policy is the policy of the stage
+}
+
+// Sequence returns a RetryPolicy that uses the policy of the first stage for
+// its first Fails failures, then switches to the policy of the next stage,
+// and so on. RetryInfo.Fails is rebased so that every stage starts counting
+// from 1. The function will return false after the last stage is over.
+//
+// Sleep formula: sleep of the current stage
+func Sequence(stages ...Stage) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		for _, stage := range stages {
+			if stage.Fails <= 0 || ri.Fails <= stage.Fails {
+				return stage.Policy(ri)
+			}
+			ri.Fails -= stage.Fails
+		}
+		ri.call.record(Decision{Reason: "all stages of Sequence are over"})
+		return false, 0
+	}
+}
@@ -0,0 +1,368 @@
+package retrygo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// counting is a stateful policy that stops after its own third call.
+func counting(calls *int) retrygo.Policy {
+	return retrygo.PolicyFunc(func() retrygo.RetryPolicy {
+		count := 0
+		return func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			count++
+			*calls = count
+			return count < 3, 0
+		}
+	})
+}
+
+// Test Stateful
+func TestStateful(t *testing.T) {
+	var lastCount int
+	retry, _ := retrygo.New[int](
+		retrygo.Combine(
+			retrygo.Stateful(counting(&lastCount)),
+			retrygo.Stateful(retrygo.LimitCount(10)),
+		),
+	)
+
+	for i := 0; i < 3; i++ {
+		attempts := 0
+		_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+			attempts++
+			return 0, fmt.Errorf("error")
+		})
+		if err == nil {
+			t.Error("expected error")
+		}
+		// Every call starts from a fresh state.
+		if attempts != 3 || lastCount != 3 {
+			t.Errorf("expected 3 attempts and count 3, got %d and %d", attempts, lastCount)
+		}
+	}
+}
+
+// Test Stateful from many goroutines
+func TestStatefulConcurrent(t *testing.T) {
+	policy := retrygo.Stateful(retrygo.PolicyFunc(func() retrygo.RetryPolicy {
+		count := 0
+		return func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			count++
+			if count != ri.Fails {
+				panic(fmt.Sprintf("state shared between calls: count %d, fails %d", count, ri.Fails))
+			}
+			return count < 5, time.Microsecond
+		}
+	}))
+	retry, _ := retrygo.New[int](policy)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+				return 0, fmt.Errorf("error")
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// Test Stateful outside of Do
+func TestStatefulDirectCall(t *testing.T) {
+	var count int
+	policy := retrygo.Stateful(counting(&count))
+	for i := 0; i < 3; i++ {
+		if continueRetry, _ := policy(retrygo.RetryInfo{Fails: 1}); !continueRetry || count != 1 {
+			t.Errorf("expected a fresh state, got count %d", count)
+		}
+	}
+}
+
+// Test Decide
+func TestDecide(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy retrygo.RetryPolicy
+		reason string
+	}{
+		{"LimitCount", retrygo.LimitCount(3), "count limit 3 reached"},
+		{"Combine", retrygo.Combine(retrygo.Constant(time.Second), retrygo.LimitCount(3)), "count limit 3 reached"},
+		{"CombineCustom", retrygo.Combine(
+			retrygo.LimitCount(5),
+			func(ri retrygo.RetryInfo) (bool, time.Duration) { return false, 0 },
+		), "policy 2 of Combine gave up"},
+		{"FromDecision", retrygo.FromDecision(func(ri retrygo.RetryInfo) retrygo.Decision {
+			return retrygo.Decision{Reason: "custom"}
+		}), "custom"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.policy.Decide(retrygo.RetryInfo{Fails: 3})
+			if d.Continue || d.Reason != tc.reason {
+				t.Errorf("expected a stop with reason %q, got %+v", tc.reason, d)
+			}
+		})
+	}
+}
+
+// Test the decision details in Do
+func TestDecisionInDo(t *testing.T) {
+	const attemptTimeout = 10 * time.Millisecond
+	retry, _ := retrygo.New[int](
+		retrygo.Combine(
+			retrygo.FromDecision(func(ri retrygo.RetryInfo) retrygo.Decision {
+				return retrygo.Decision{Continue: true, AttemptTimeout: attemptTimeout}
+			}),
+			retrygo.LimitCount(3),
+		),
+	)
+
+	var deadlines []bool
+	_, err := retry.Do(context.Background(), func(ctx context.Context) (int, error) {
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		return 0, fmt.Errorf("error")
+	})
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.PolicyReason != "count limit 3 reached" {
+		t.Fatalf("expected the reason of LimitCount, got %v", err)
+	}
+	// The first attempt runs before any decision.
+	if len(deadlines) != 3 || deadlines[0] || !deadlines[1] || !deadlines[2] {
+		t.Errorf("unexpected attempt deadlines %v", deadlines)
+	}
+}
+
+// fixed returns a RetryPolicy that always returns the given decision.
+func fixed(continueRetry bool, sleep time.Duration) retrygo.RetryPolicy {
+	return func(retrygo.RetryInfo) (bool, time.Duration) {
+		return continueRetry, sleep
+	}
+}
+
+// Test Any, First, MaxSleep and MinSleep
+func TestCombinators(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		policy        retrygo.RetryPolicy
+		continueRetry bool
+		sleep         time.Duration
+	}{
+		{"AnyAllContinue", retrygo.Any(fixed(true, 1), fixed(true, 2)), true, 3},
+		{"AnySomeContinue", retrygo.Any(fixed(false, 1), fixed(true, 2), fixed(true, 4)), true, 6},
+		{"AnyNoneContinue", retrygo.Any(fixed(false, 1), fixed(false, 2)), false, 0},
+		{"FirstContinue", retrygo.First(fixed(false, 1), fixed(true, 2), fixed(true, 4)), true, 2},
+		{"FirstNoneContinue", retrygo.First(fixed(false, 1), fixed(false, 2)), false, 0},
+		{"MaxSleep", retrygo.MaxSleep(fixed(true, 1), fixed(true, 4), fixed(true, 2)), true, 4},
+		{"MaxSleepStop", retrygo.MaxSleep(fixed(true, 1), fixed(false, 4)), false, 0},
+		{"MinSleep", retrygo.MinSleep(fixed(true, 3), fixed(true, 1), fixed(true, 2)), true, 1},
+		{"MinSleepStop", retrygo.MinSleep(fixed(false, 1), fixed(true, 4)), false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			continueRetry, sleep := tc.policy(retrygo.RetryInfo{Fails: 1})
+			if continueRetry != tc.continueRetry || sleep != tc.sleep {
+				t.Errorf("expected %t and %s, got %t and %s", tc.continueRetry, tc.sleep, continueRetry, sleep)
+			}
+		})
+	}
+}
+
+// Test Any stops when all the limits hit
+func TestAnyReason(t *testing.T) {
+	policy := retrygo.Any(retrygo.LimitCount(2), retrygo.LimitCount(3))
+	if d := policy.Decide(retrygo.RetryInfo{Fails: 2}); !d.Continue {
+		t.Errorf("expected to continue, got %+v", d)
+	}
+	if d := policy.Decide(retrygo.RetryInfo{Fails: 3}); d.Continue || d.Reason != "count limit 2 reached" {
+		t.Errorf("expected to stop with the reason of the first policy, got %+v", d)
+	}
+	if d := retrygo.MaxSleep(fixed(true, 0), fixed(false, 0)).Decide(retrygo.RetryInfo{}); d.Reason != "policy 2 of MaxSleep gave up" {
+		t.Errorf("unexpected reason %q", d.Reason)
+	}
+}
+
+// Test Sequence
+func TestSequence(t *testing.T) {
+	policy := retrygo.Sequence(
+		retrygo.Stage{Fails: 2, Policy: retrygo.Constant(time.Millisecond)},
+		retrygo.Stage{Fails: 3, Policy: retrygo.Linear(time.Second)},
+		retrygo.Stage{Policy: retrygo.Constant(time.Minute)},
+	)
+	requiredValues := []time.Duration{
+		time.Millisecond, time.Millisecond,
+		1 * time.Second, 2 * time.Second, 3 * time.Second,
+		time.Minute, time.Minute,
+	}
+	info := retrygo.RetryInfo{Fails: 1}
+	for _, expectedValue := range requiredValues {
+		continueRetry, sleep := policy(info)
+		if !continueRetry || sleep != expectedValue {
+			t.Errorf("fails=%d: expected true and %s, got %t and %s", info.Fails, expectedValue, continueRetry, sleep)
+		}
+		info.Fails++
+	}
+
+	bounded := retrygo.Sequence(
+		retrygo.Stage{Fails: 1, Policy: retrygo.Constant(time.Second)},
+		retrygo.Stage{Fails: 1, Policy: retrygo.Constant(time.Minute)},
+	)
+	if d := bounded.Decide(retrygo.RetryInfo{Fails: 3}); d.Continue || d.Reason != "all stages of Sequence are over" {
+		t.Errorf("expected to stop after the last stage, got %+v", d)
+	}
+}
+
+// Test LimitConsecutive
+func TestLimitConsecutive(t *testing.T) {
+	errTimeout := fmt.Errorf("timeout")
+	retry, _ := retrygo.New[int](
+		retrygo.Combine(
+			retrygo.LimitConsecutive(retrygo.MatchIs(errTimeout), 3),
+			retrygo.LimitCount(20),
+		),
+	)
+
+	// Two timeouts in a row never hit the limit, the third one does.
+	pattern := []bool{true, true, false, true, true, false, true, true, true, true}
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if pattern[calls-1] {
+			return 0, errTimeout
+		}
+		return 0, fmt.Errorf("other")
+	})
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.PolicyReason != "consecutive limit 3 reached" {
+		t.Fatalf("expected the consecutive limit, got %v", err)
+	}
+	if calls != 9 {
+		t.Errorf("expected 9 calls, got %d", calls)
+	}
+}
+
+// Test ResetOnErrorChange
+func TestResetOnErrorChange(t *testing.T) {
+	var sleeps []time.Duration
+	backoff := retrygo.ResetOnErrorChange(retrygo.Exponential(time.Millisecond))
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			_, sleep := backoff(ri)
+			sleeps = append(sleeps, sleep)
+			return ri.Fails < 6, 0
+		},
+	)
+
+	errs := []error{
+		errors.New("a"), errors.New("a"), errors.New("a"),
+		&testError{}, &testError{},
+		errors.New("b"),
+	}
+	calls := 0
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		return 0, errs[calls-1]
+	})
+
+	expected := []time.Duration{1, 2, 4, 1, 2, 1}
+	for i := range expected {
+		expected[i] *= time.Millisecond
+	}
+	if fmt.Sprint(sleeps) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, sleeps)
+	}
+}
+
+// Test that ResetOnErrorChange restarts the stateful policies it wraps
+func TestResetOnErrorChangeStateful(t *testing.T) {
+	var fails []int
+	inner := func(ri retrygo.RetryInfo) (bool, time.Duration) {
+		fails = append(fails, ri.Fails)
+		return true, 0
+	}
+	retry, _ := retrygo.New[int](
+		retrygo.Combine(
+			retrygo.ResetOnErrorChange(retrygo.Switch(retrygo.CaseDefault(inner))),
+			retrygo.LimitCount(6),
+		),
+	)
+	errA, errB := errors.New("a"), errors.New("b")
+	calls := 0
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls <= 3 {
+			return 0, errA
+		}
+		return 0, errB
+	})
+	if fmt.Sprint(fails) != "[1 2 3 1 2 3]" {
+		t.Errorf("expected [1 2 3 1 2 3], got %v", fails)
+	}
+}
+
+// Test that alternating sentinel errors are different classes
+func TestResetOnErrorChangeSentinels(t *testing.T) {
+	errTimeout, errReset := errors.New("timeout"), errors.New("connection reset")
+	var consecutive []int
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			consecutive = append(consecutive, ri.Consecutive)
+			return ri.Fails < 4, 0
+		},
+	)
+	calls := 0
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls%2 == 0 {
+			return 0, errReset
+		}
+		return 0, fmt.Errorf("attempt %d: %w", calls, errTimeout)
+	})
+	if fmt.Sprint(consecutive) != "[1 1 1 1]" {
+		t.Errorf("expected [1 1 1 1], got %v", consecutive)
+	}
+}
+
+// Test that Any and First discard the reasons of the policies they override
+func TestNestedReason(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   retrygo.RetryPolicy
+		expected string
+	}{
+		{
+			name:     "Any",
+			policy:   retrygo.Combine(retrygo.Any(retrygo.LimitCount(2), retrygo.Constant(0)), retrygo.LimitCount(5)),
+			expected: "retrygo: policy gave up (count limit 5 reached) after 5 attempts: boom",
+		},
+		{
+			name:     "First",
+			policy:   retrygo.Combine(retrygo.First(retrygo.LimitCount(1), retrygo.Constant(0)), retrygo.LimitCount(3)),
+			expected: "retrygo: policy gave up (count limit 3 reached) after 3 attempts: boom",
+		},
+		{
+			name:     "FirstAllGaveUp",
+			policy:   retrygo.First(retrygo.LimitCount(1), retrygo.LimitCount(2)),
+			expected: "retrygo: policy gave up (count limit 1 reached) after 2 attempts: boom",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, _ := retrygo.New[int](tc.policy)
+			_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+				return 0, errors.New("boom")
+			})
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected %s, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package retrygo
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Rand is a source of random numbers for the jittered policies.
+// *rand.Rand from math/rand/v2 implements it.
+type Rand interface {
+	Int64N(n int64) int64
+	Float64() float64
+	NormFloat64() float64
+	ExpFloat64() float64
+}
+
+// defaultRand is the source used when none is set. It is randomly seeded,
+// safe for concurrent use and does not lock.
+var defaultRand Rand = globalRand{}
+
+// globalRand is a Rand backed by the top-level functions of math/rand/v2.
+type globalRand struct{}
+
+func (globalRand) Int64N(n int64) int64 { return rand.Int64N(n) }
+func (globalRand) Float64() float64     { return rand.Float64() }
+func (globalRand) NormFloat64() float64 { return rand.NormFloat64() }
+func (globalRand) ExpFloat64() float64  { return rand.ExpFloat64() }
+
+// NewRand returns a deterministic Rand seeded with seed. It is safe for
+// concurrent use, but the sequence is only reproducible when it is used from
+// a single goroutine.
+func NewRand(seed uint64) Rand {
+	return &lockedRand{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// lockedRand is a Rand guarded by a mutex.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Int64N(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int64N(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) NormFloat64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.NormFloat64()
+}
+
+func (l *lockedRand) ExpFloat64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.ExpFloat64()
+}
+
+// UseRand returns a RetryPolicy that runs the policy with r as the source of
+// random numbers of the jittered policies, overriding the one set with the
+// WithRand option.
+func UseRand(policy RetryPolicy, r Rand) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		if ri.call == nil {
+			ri.call = &callState{}
+		}
+		previous := ri.call.rand
+		ri.call.rand = r
+		defer func() { ri.call.rand = previous }()
+		return policy(ri)
+	}
+}
+
+// random returns the source of random numbers for the policies.
+func (ri RetryInfo) random() Rand {
+	if ri.call != nil && ri.call.rand != nil {
+		return ri.call.rand
+	}
+	return defaultRand
+}
+
+// randDuration returns a random duration in [0, d), or 0 if d is not positive.
+func randDuration(r Rand, d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(r.Int64N(int64(d)))
+}
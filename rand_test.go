@@ -0,0 +1,96 @@
+package retrygo_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// schedule returns the sleeps planned by the policy during a Do call.
+func schedule(policy retrygo.RetryPolicy, options ...retrygo.RetryOption[int]) []time.Duration {
+	var sleeps []time.Duration
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			_, sleep := policy(ri)
+			sleeps = append(sleeps, sleep)
+			return ri.Fails < 10, 0
+		},
+		options...,
+	)
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		return 0, fmt.Errorf("error")
+	})
+	return sleeps
+}
+
+// Test WithRand
+func TestWithRand(t *testing.T) {
+	policy := retrygo.Combine(
+		retrygo.FullJitter(time.Second, time.Minute),
+		retrygo.DecorrelatedJitter(time.Second, time.Minute),
+		retrygo.WithJitter(retrygo.Constant(time.Second), retrygo.GaussianDist(0.5)),
+	)
+	first := schedule(policy, retrygo.WithRand[int](retrygo.NewRand(42)))
+	second := schedule(policy, retrygo.WithRand[int](retrygo.NewRand(42)))
+	if !slices.Equal(first, second) {
+		t.Errorf("expected the same schedule, got %v and %v", first, second)
+	}
+	third := schedule(policy, retrygo.WithRand[int](retrygo.NewRand(43)))
+	if slices.Equal(first, third) {
+		t.Errorf("expected different schedules for different seeds, got %v", first)
+	}
+
+	if _, err := retrygo.New[int](policy, retrygo.WithRand[int](nil)); err == nil {
+		t.Error("expected error for a nil random source")
+	}
+}
+
+// Test UseRand
+func TestUseRand(t *testing.T) {
+	policy := retrygo.Jitter(time.Second)
+	first := schedule(retrygo.UseRand(policy, retrygo.NewRand(7)))
+	// UseRand overrides WithRand.
+	second := schedule(retrygo.UseRand(policy, retrygo.NewRand(7)), retrygo.WithRand[int](retrygo.NewRand(8)))
+	if !slices.Equal(first, second) {
+		t.Errorf("expected the same schedule, got %v and %v", first, second)
+	}
+
+	// UseRand works outside of Do.
+	a, b := retrygo.UseRand(policy, retrygo.NewRand(7)), retrygo.UseRand(policy, retrygo.NewRand(7))
+	for i := 0; i < 10; i++ {
+		_, x := a(retrygo.RetryInfo{Fails: 1})
+		_, y := b(retrygo.RetryInfo{Fails: 1})
+		if x != y {
+			t.Errorf("expected the same sleep, got %s and %s", x, y)
+		}
+	}
+}
+
+// Test the random sources from many goroutines
+func TestRandConcurrent(t *testing.T) {
+	for name, option := range map[string][]retrygo.RetryOption[int]{
+		"Default": nil,
+		"Seeded":  {retrygo.WithRand[int](retrygo.NewRand(1))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			policy := retrygo.Combine(retrygo.LimitCount(10), retrygo.FullJitter(time.Microsecond, 0))
+			retry, _ := retrygo.New[int](policy, option...)
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+						return 0, fmt.Errorf("error")
+					})
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
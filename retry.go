@@ -2,15 +2,41 @@ package retrygo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"time"
 )
 
 type zero struct{}
 
+const defaultHistory = 10
+
 // Retry is the main type of this package.
 type Retry[T any] struct {
-	policy   RetryPolicy
-	recovery bool
+	policy    RetryPolicy
+	name      string
+	recovery  bool
+	repanic   func(any) bool
+	retryable []func(error) bool
+	retryOn   []error
+	afterMin  bool
+	history   int
+	rand      Rand
+	classify  func(error) string
+	deadline  DeadlineMode
+
+	attemptTimeout func(RetryInfo) time.Duration
+	abandon        bool
+	onAbandoned    func(T, error)
+	rejected       []func(T) bool
+	discard        func(T)
+
+	fallback         func(context.Context, error, RetryInfo) (T, error)
+	fallbackOnCancel bool
+
+	hooks  hooks
+	tracer Tracer
 }
 
 // type RetryOption[T any] func(*Retry[T])
@@ -27,6 +53,17 @@ func (o option[T]) apply(r *Retry[T]) error {
 	return o.f(r)
 }
 
+// WithName sets the name of the Retry, which tells it apart in the logs and
+// the metrics.
+func WithName[T any](name string) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			r.name = name
+			return nil
+		},
+	}
+}
+
 // WithRecovery enables the recovery mode.
 func WithRecovery[T any]() RetryOption[T] {
 	return option[T]{
@@ -37,10 +74,299 @@ func WithRecovery[T any]() RetryOption[T] {
 	}
 }
 
+// WithRepanic sets which panics are not recovered in the recovery mode: the
+// panics for which repanic returns true are re-panicked by Do, after the
+// result of the last failed attempt is passed to the discard hook. For
+// example, WithRepanic(IsRuntimeError) retries the application panics but
+// not the programming errors.
+func WithRepanic[T any](repanic func(v any) bool) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if repanic == nil {
+				return errors.New("retrygo: nil repanic predicate")
+			}
+			r.repanic = repanic
+			return nil
+		},
+	}
+}
+
+// WithRetryIf retries only the errors for which pred returns true.
+// Any other error is returned right away, without consulting the RetryPolicy
+// and without being counted in RetryInfo.Fails. With several WithRetryIf and
+// WithStopOn options, an error is retried only if all of them allow it.
+func WithRetryIf[T any](pred func(error) bool) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if pred == nil {
+				return errors.New("retrygo: nil retry predicate")
+			}
+			r.retryable = append(r.retryable, pred)
+			return nil
+		},
+	}
+}
+
+// WithRetryOn retries only the errors that match one of errs (errors.Is).
+// Any other error is returned right away. Several WithRetryOn options add up:
+// an error is retried if it matches any of their errs.
+func WithRetryOn[T any](errs ...error) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			// A non-nil retryOn retries only its errors, even if empty.
+			if r.retryOn == nil {
+				r.retryOn = []error{}
+			}
+			r.retryOn = append(r.retryOn, errs...)
+			return nil
+		},
+	}
+}
+
+// WithStopOn returns the errors that match one of the targets right away.
+// A target is either an error, matched with errors.Is, or a non-nil pointer
+// to an error or interface type, matched with errors.As.
+func WithStopOn[T any](targets ...any) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			matchers := make([]func(error) bool, 0, len(targets))
+			for _, target := range targets {
+				match, err := matchTarget(target)
+				if err != nil {
+					return err
+				}
+				matchers = append(matchers, match)
+			}
+			r.retryable = append(r.retryable, func(err error) bool {
+				for _, match := range matchers {
+					if match(err) {
+						return false
+					}
+				}
+				return true
+			})
+			return nil
+		},
+	}
+}
+
+// WithRetryAfterMin treats the delay of a RetryAfter error as the minimum
+// sleep instead of the exact one: the longer of the policy's sleep and the
+// requested delay is used.
+func WithRetryAfterMin[T any]() RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			r.afterMin = true
+			return nil
+		},
+	}
+}
+
+// WithHistory sets how many errors of the earlier attempts are kept in
+// ErrExhausted.History. The default is 10, 0 disables the history.
+func WithHistory[T any](n int) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if n < 0 {
+				return errors.New("retrygo: negative history size")
+			}
+			r.history = n
+			return nil
+		},
+	}
+}
+
+// WithRand sets the source of random numbers of the jittered policies.
+// By default a randomly seeded source, safe for concurrent use, is used.
+func WithRand[T any](rand Rand) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if rand == nil {
+				return errors.New("retrygo: nil random source")
+			}
+			r.rand = rand
+			return nil
+		},
+	}
+}
+
+// WithErrorClass sets the function that returns the class of an error, used
+// to fill RetryInfo.Class, ClassFails and Consecutive. By default the class
+// is the type of the innermost error of the chain. The errors made by
+// errors.New and by fmt.Errorf without %w have the same type, so their class
+// also includes the message: two sentinel errors are two classes, but so are
+// two messages that only differ by a formatted value. An error that wraps
+// several errors, e.g. with errors.Join or several %w, has the classes of all
+// of them, joined with " + ".
+func WithErrorClass[T any](classify func(error) string) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if classify == nil {
+				return errors.New("retrygo: nil error classifier")
+			}
+			r.classify = classify
+			return nil
+		},
+	}
+}
+
+// WithAttemptTimeout limits every attempt to d: the function gets a context
+// that expires after d. The error of an attempt that timed out wraps
+// ErrAttemptTimeout and is always retryable, unless it is permanent.
+func WithAttemptTimeout[T any](d time.Duration) RetryOption[T] {
+	if d <= 0 {
+		return option[T]{
+			f: func(r *Retry[T]) error {
+				return errors.New("retrygo: non-positive attempt timeout")
+			},
+		}
+	}
+	return WithAttemptTimeoutFunc[T](func(RetryInfo) time.Duration { return d })
+}
+
+// WithAttemptTimeoutFunc limits every attempt to the duration returned by
+// timeout, which gets the RetryInfo of the previous attempt (Attempt is 0
+// before the first one), e.g. to grow the timeout with every attempt.
+// A non-positive duration means no limit. See WithAttemptTimeout.
+func WithAttemptTimeoutFunc[T any](timeout func(RetryInfo) time.Duration) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if timeout == nil {
+				return errors.New("retrygo: nil attempt timeout function")
+			}
+			r.attemptTimeout = timeout
+			return nil
+		},
+	}
+}
+
+// WithAbandonOnCancel runs every attempt in its own goroutine, so that Do
+// returns as soon as the context is done, even if the function ignores its
+// context. The abandoned attempt keeps running in the background: when it
+// returns, its result and error go to onAbandoned, if not nil, e.g. to close
+// a response body. A panic of an abandoned attempt is passed as an
+// ErrRecovered error. onAbandoned is called from the goroutine of the
+// abandoned attempt, concurrently with Do and with the other abandoned
+// attempts, so it must be safe for concurrent use.
+//
+// With WithAttemptTimeout or WithAttemptTimeoutFunc, an attempt is also
+// abandoned when its own timeout expires: it fails with ErrAttemptTimeout and
+// is retried like any timed out attempt. The next attempt doesn't start before
+// the abandoned one returns, and waiting for it counts against the timeout of
+// the next attempt. So a Do call runs at most one attempt at a time, and
+// leaves at most one goroutine behind, until the abandoned attempt returns.
+func WithAbandonOnCancel[T any](onAbandoned func(T, error)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			r.abandon = true
+			r.onAbandoned = onAbandoned
+			return nil
+		},
+	}
+}
+
+// WithRetryOnResult retries the results for which pred returns true, even
+// though the function returned no error, e.g. a pending job status. Such an
+// attempt fails with ErrResultRejected, which is always retryable. When Do
+// gives up, it still returns the last result.
+func WithRetryOnResult[T any](pred func(T) bool) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if pred == nil {
+				return errors.New("retrygo: nil result predicate")
+			}
+			r.rejected = append(r.rejected, pred)
+			return nil
+		},
+	}
+}
+
+// WithDiscard sets a hook that gets every result that Do does not return: the
+// results of the failed attempts that are followed by another attempt, the
+// late results of the abandoned attempts (after the callback of
+// WithAbandonOnCancel) and the last result when the context is done, in which
+// case Do returns the zero value. Use it to release resources such as
+// response bodies. The hook may get zero values.
+//
+// The late results of the abandoned attempts are passed from the goroutines
+// of these attempts, concurrently with the Do calls, so the hook must be safe
+// for concurrent use.
+func WithDiscard[T any](discard func(T)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if discard == nil {
+				return errors.New("retrygo: nil discard hook")
+			}
+			r.discard = discard
+			return nil
+		},
+	}
+}
+
+// WithFallback sets a function that provides the result when Do gives up,
+// e.g. a cached or default value. It runs when the policy gives up, the error
+// is permanent or the deadline is too close (see WithDeadlineMode) and, with
+// WithFallbackOnCancel, when the context is done.
+// It gets the *ErrExhausted that Do would return and the last RetryInfo.
+//
+// The error returned by Do wraps both ErrFallbackUsed and the *ErrExhausted,
+// so the caller can tell the result comes from the fallback. If the fallback
+// fails, the error also wraps the error of the fallback.
+func WithFallback[T any](fallback func(ctx context.Context, err error, ri RetryInfo) (T, error)) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if fallback == nil {
+				return errors.New("retrygo: nil fallback")
+			}
+			r.fallback = fallback
+			return nil
+		},
+	}
+}
+
+// WithFallbackOnCancel runs the fallback of WithFallback also when the
+// context is done. The fallback then gets a done context.
+func WithFallbackOnCancel[T any]() RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			r.fallbackOnCancel = true
+			return nil
+		},
+	}
+}
+
+// DeadlineMode tells what Do does when the next attempt would not end before
+// the context deadline. The end of the next attempt is estimated as the end of
+// the sleep plus the duration of the last attempt.
+type DeadlineMode int
+
+const (
+	DeadlineIgnore   DeadlineMode = iota // DeadlineIgnore sleeps anyway, the default
+	DeadlineStop                         // DeadlineStop stops right away with the last error
+	DeadlineTruncate                     // DeadlineTruncate shortens the sleep so a final attempt fits
+)
+
+// WithDeadlineMode sets what Do does when the next attempt would not end
+// before the context deadline. With DeadlineStop and DeadlineTruncate, Do
+// returns the last error instead of waiting for the context to expire.
+func WithDeadlineMode[T any](mode DeadlineMode) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if mode < DeadlineIgnore || mode > DeadlineTruncate {
+				return fmt.Errorf("retrygo: unknown deadline mode %d", mode)
+			}
+			r.deadline = mode
+			return nil
+		},
+	}
+}
+
 // New creates a new Retry instance with the given RetryPolicy and RetryOptions.
 func New[T any](policy RetryPolicy, options ...RetryOption[T]) (Retry[T], error) {
 	r := Retry[T]{
-		policy: policy,
+		policy:   policy,
+		history:  defaultHistory,
+		classify: errorClass,
 	}
 	for _, opt := range options {
 		if err := opt.apply(&r); err != nil {
@@ -56,93 +382,394 @@ func NewZero(policy RetryPolicy, options ...RetryOption[zero]) (Retry[zero], err
 }
 
 // Do calls the given function f until it returns nil error or the context is done.
+//
+// When Do stops without a success, the returned error is an *ErrExhausted that
+// wraps the last error of f and, if the context is done, the context error.
+// Do returns the last result of f, or the zero value if the context is done.
+// With WithFallback, Do returns the result of the fallback instead.
 func (r Retry[T]) Do(ctx context.Context, f func(context.Context) (T, error)) (T, error) {
-	if !r.recovery {
-		return r.do(ctx, f)
-	} else {
-		return r.doRecovery(ctx, f)
+	ctx, tr := startTrace(ctx, r.tracer, r.name)
+	defer tr.end()
+	result, ri, exhausted := r.do(ctx, tr, f)
+	if exhausted == nil {
+		return result, nil
 	}
-}
-
-// DoZero calls the given function f until it returns nil error or the context is done.
-func (r Retry[T]) DoZero(ctx context.Context, f func(context.Context) error) error {
-	// fw is a function wrapper for f.
-	fw := func(ctx context.Context) (T, error) {
-		var zeroValue T
-		return zeroValue, f(ctx)
+	if r.fallback == nil || (exhausted.Reason == StopContext && !r.fallbackOnCancel) {
+		return result, exhausted
 	}
-	_, err := r.Do(ctx, fw)
-	return err
+	if exhausted.Reason != StopContext {
+		r.drop(result)
+	}
+	result, err := r.fallback(ctx, exhausted, ri)
+	if err != nil {
+		return result, fmt.Errorf("%w, but failed: %w: %w", ErrFallbackUsed, err, exhausted)
+	}
+	return result, fmt.Errorf("%w: %w", ErrFallbackUsed, exhausted)
 }
 
-func (r Retry[T]) do(ctx context.Context, f func(context.Context) (T, error)) (T, error) {
+// do runs the retry loop. It returns the last result with the RetryInfo, and
+// a nil *ErrExhausted on success.
+func (r Retry[T]) do(ctx context.Context, tr *trace, f func(context.Context) (T, error)) (T, RetryInfo, *ErrExhausted) {
 	ri := RetryInfo{
 		Fails: 0,
 		Since: time.Now(),
 		Err:   nil,
+		call:  &callState{ctx: ctx, name: r.name, rand: r.rand},
+	}
+	var (
+		result   T
+		history  []error
+		decision Decision
+		classes  []classCount
+	)
+	exhausted := func(reason StopReason, cause error) *ErrExhausted {
+		e := &ErrExhausted{
+			Reason:   reason,
+			Attempts: ri.Attempt,
+			Elapsed:  time.Since(ri.Since),
+			Slept:    ri.TotalSleep,
+			Err:      ri.Err,
+			Cause:    cause,
+			History:  history,
+		}
+		if reason == StopPolicy {
+			e.PolicyReason = decision.Reason
+		}
+		tr.giveUp(e)
+		r.hooks.giveUp(ri, e)
+		return e
+	}
+	// canceled drops the result of the last failed attempt, if any.
+	canceled := func(cause error) (T, RetryInfo, *ErrExhausted) {
+		if ri.Err != nil {
+			r.drop(result)
+		}
+		var zeroValue T
+		return zeroValue, ri, exhausted(StopContext, cause)
 	}
-	var result T
-	timer := time.NewTimer(0)
 	for {
-		select {
-		case <-ctx.Done():
-			return result, ctx.Err()
-		default:
+		if err := ctx.Err(); err != nil {
+			return canceled(err)
+		}
+		// The timeout function gets the RetryInfo of the previous attempt.
+		timeout := r.timeout(ri, decision)
+		ri.Attempt++
+		ri.AttemptStart = time.Now()
+		r.hooks.attempt(ri)
+		attemptResult, err := r.call(tr.startAttempt(ctx, ri.Attempt), ri.call, f, timeout)
+		ri.AttemptDuration = time.Since(ri.AttemptStart)
+		if errors.Is(err, errAbandoned) {
+			return canceled(ctx.Err())
+		}
+		var p repanic
+		if errors.As(err, &p) {
+			if ri.Err != nil {
+				r.drop(result)
+			}
+			panic(p.v)
+		}
+		if ri.Err != nil && r.history > 0 {
+			if len(history) == r.history {
+				history = history[1:]
+			}
+			history = append(history, ri.Err)
+		}
+		if ri.Err != nil {
+			r.drop(result)
+		}
+		result, ri.Err = attemptResult, err
+		ri.Result = result
+		if r.recovery && errors.As(err, new(ErrRecovered)) {
+			r.hooks.recover(ri)
+		}
+		if ri.Err == nil && r.isRejected(result) {
+			ri.Err = ErrResultRejected
 		}
-		result, ri.Err = f(ctx)
 		if ri.Err == nil {
-			return result, nil
+			tr.success(ri)
+			r.hooks.success(ri)
+			return result, ri, nil
+		}
+		tr.failed(ri.Err)
+		if !r.isRetryable(ri.Err) {
+			return result, ri, exhausted(StopPermanent, nil)
 		}
 		ri.Fails++
-		continueRetry, sleep := r.policy(ri)
-		if !continueRetry {
-			return result, ri.Err
+		class := r.classify(ri.Err)
+		if ri.Consecutive > 0 && class == ri.Class {
+			ri.Consecutive++
+		} else {
+			ri.Consecutive = 1
 		}
-		timer.Reset(sleep)
-		select {
-		case <-timer.C:
-		case <-ctx.Done():
-			return result, ctx.Err()
+		ri.Class = class
+		classes, ri.ClassFails = countClass(classes, class)
+		decision = r.policy.Decide(ri)
+		if !decision.Continue {
+			return result, ri, exhausted(StopPolicy, nil)
+		}
+		sleep, ok := r.fitDeadline(ctx, r.retryAfter(ri.Err, decision.Sleep), ri.AttemptDuration)
+		if !ok {
+			return result, ri, exhausted(StopDeadline, nil)
+		}
+		tr.retry(ri, sleep)
+		r.hooks.retry(ri, sleep)
+		start := time.Now()
+		if err := wait(ctx, sleep); err != nil {
+			ri.TotalSleep += time.Since(start)
+			return canceled(err)
 		}
+		ri.LastSleep = sleep
+		ri.TotalSleep += sleep
 	}
 }
 
-func (r Retry[T]) doRecovery(ctx context.Context, f func(context.Context) (T, error)) (T, error) {
-	ri := RetryInfo{
-		Fails: 0,
-		Since: time.Now(),
-		Err:   nil,
+// DoZero calls the given function f until it returns nil error or the context is done.
+func (r Retry[T]) DoZero(ctx context.Context, f func(context.Context) error) error {
+	// fw is a function wrapper for f.
+	fw := func(ctx context.Context) (T, error) {
+		var zeroValue T
+		return zeroValue, f(ctx)
 	}
-	var result T
-	timer := time.NewTimer(0)
-	for {
+	_, err := r.Do(ctx, fw)
+	return err
+}
+
+// call runs a single attempt, limited by the timeout if it is positive.
+// An error of an attempt that timed out wraps ErrAttemptTimeout.
+func (r Retry[T]) call(ctx context.Context, call *callState, f func(context.Context) (T, error), timeout time.Duration) (result T, err error) {
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeoutCause(ctx, timeout, ErrAttemptTimeout)
+		defer cancel()
+		defer func() {
+			if err != nil && err != errAbandoned && context.Cause(attemptCtx) == ErrAttemptTimeout {
+				err = fmt.Errorf("%w: %w", ErrAttemptTimeout, err)
+			}
+		}()
+	}
+	if r.abandon {
+		return r.runAbandonable(ctx, attemptCtx, call, f)
+	}
+	return r.run(attemptCtx, f)
+}
+
+// run calls f. In the recovery mode a panic is turned into an ErrRecovered
+// error, or a repanic error for the panics that must be re-panicked.
+func (r Retry[T]) run(ctx context.Context, f func(context.Context) (T, error)) (result T, err error) {
+	if r.recovery {
+		defer func() {
+			if v := recover(); v != nil {
+				if r.repanic != nil && r.repanic(v) {
+					err = repanic{v: v}
+					return
+				}
+				err = ErrRecovered{V: v, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return f(ctx)
+}
+
+// errAbandoned is returned by runAbandonable when the attempt is abandoned.
+var errAbandoned = errors.New("retrygo: attempt abandoned")
+
+// outcome is the outcome of an attempt run in its own goroutine.
+type outcome[T any] struct {
+	result T
+	err    error
+	panic  any
+	stack  []byte
+}
+
+// runAbandonable runs f in its own goroutine and returns errAbandoned as soon
+// as ctx is done, or the error of attemptCtx as soon as only attemptCtx is
+// done, i.e. the attempt timed out. The late outcome of an abandoned attempt
+// goes to the onAbandoned callback, a panic as an ErrRecovered error. A panic
+// of an attempt that is not abandoned is re-panicked in the calling goroutine.
+//
+// f is not called while the previous abandoned attempt of the call is still
+// running, so a Do call has at most one attempt running at a time.
+func (r Retry[T]) runAbandonable(ctx, attemptCtx context.Context, call *callState, f func(context.Context) (T, error)) (T, error) {
+	var zeroValue T
+	if call.running != nil {
 		select {
-		case <-ctx.Done():
-			return result, ctx.Err()
-		default:
+		case <-call.running:
+			call.running = nil
+		case <-attemptCtx.Done():
+			return zeroValue, abandonedErr(ctx, attemptCtx)
 		}
+	}
+	done := make(chan outcome[T])
+	abandoned := make(chan struct{})
+	running := make(chan struct{})
+	go func() {
+		defer close(running)
+		var o outcome[T]
 		func() {
 			defer func() {
-				if r := recover(); r != nil {
-					ri.Err = ErrRecovered{V: r}
+				if v := recover(); v != nil {
+					o.panic, o.stack = v, debug.Stack()
 				}
 			}()
-			result, ri.Err = f(ctx)
+			o.result, o.err = r.run(attemptCtx, f)
 		}()
-		if ri.Err == nil {
-			return result, nil
+		select {
+		case done <- o:
+		case <-abandoned:
+			var p repanic
+			if errors.As(o.err, &p) {
+				o.err = ErrRecovered{V: p.v}
+			}
+			if o.panic != nil {
+				o.err = ErrRecovered{V: o.panic, Stack: o.stack}
+			}
+			if r.onAbandoned != nil {
+				r.onAbandoned(o.result, o.err)
+			}
+			r.drop(o.result)
 		}
-		ri.Fails++
-		continueRetry, sleep := r.policy(ri)
-		if !continueRetry {
-			return result, ri.Err
+	}()
+	select {
+	case o := <-done:
+		if o.panic != nil {
+			panic(o.panic)
 		}
-		timer.Reset(sleep)
-		select {
-		case <-timer.C:
-		case <-ctx.Done():
-			timer.Stop()
-			return result, ctx.Err()
+		return o.result, o.err
+	case <-attemptCtx.Done():
+		close(abandoned)
+		call.running = running
+		return zeroValue, abandonedErr(ctx, attemptCtx)
+	}
+}
+
+// abandonedErr returns the error of an abandoned attempt: errAbandoned if ctx
+// is done, the error of attemptCtx if only the attempt timed out.
+func abandonedErr(ctx, attemptCtx context.Context) error {
+	if ctx.Err() == nil {
+		return attemptCtx.Err()
+	}
+	return errAbandoned
+}
+
+// drop passes a result that is not returned to the discard hook, if any.
+func (r Retry[T]) drop(result T) {
+	if r.discard != nil {
+		r.discard(result)
+	}
+}
+
+// isRejected reports whether one of the result predicates rejects result.
+func (r Retry[T]) isRejected(result T) bool {
+	for _, rejected := range r.rejected {
+		if rejected(result) {
+			return true
 		}
 	}
+	return false
+}
+
+// isRetryable reports whether err is not permanent and either comes from an
+// attempt that timed out or a rejected result, or passes all the retry
+// predicates.
+func (r Retry[T]) isRetryable(err error) bool {
+	var permanent ErrPermanent
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if err == ErrResultRejected || errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+	for _, retryable := range r.retryable {
+		if !retryable(err) {
+			return false
+		}
+	}
+	if r.retryOn == nil {
+		return true
+	}
+	for _, target := range r.retryOn {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the sleep requested by a RetryAfter error, if any.
+func (r Retry[T]) retryAfter(err error, sleep time.Duration) time.Duration {
+	var after ErrRetryAfter
+	if !errors.As(err, &after) {
+		return sleep
+	}
+	if r.afterMin && sleep > after.After {
+		return sleep
+	}
+	return after.After
+}
+
+// timeout returns the timeout of the next attempt: the shorter of the one
+// set by the options and the one of the policy decision, 0 if there is none.
+func (r Retry[T]) timeout(ri RetryInfo, decision Decision) time.Duration {
+	timeout := decision.AttemptTimeout
+	if r.attemptTimeout != nil {
+		if d := r.attemptTimeout(ri); d > 0 && (timeout <= 0 || d < timeout) {
+			timeout = d
+		}
+	}
+	return timeout
+}
+
+// fitDeadline adjusts the sleep to the context deadline according to the
+// deadline mode. It reports false if Do must stop.
+func (r Retry[T]) fitDeadline(ctx context.Context, sleep, attempt time.Duration) (time.Duration, bool) {
+	if r.deadline == DeadlineIgnore {
+		return sleep, true
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return sleep, true
+	}
+	left := time.Until(deadline) - attempt
+	switch {
+	case sleep < left:
+		return sleep, true
+	case r.deadline == DeadlineTruncate && left > 0:
+		return left, true
+	default:
+		return 0, false
+	}
+}
+
+// classCount is the number of failures with an error of a class.
+type classCount struct {
+	class string
+	fails int
+}
+
+// countClass counts a failure of the class and returns the updated counts
+// with the failures of the class so far.
+func countClass(classes []classCount, class string) ([]classCount, int) {
+	for i := range classes {
+		if classes[i].class == class {
+			classes[i].fails++
+			return classes, classes[i].fails
+		}
+	}
+	return append(classes, classCount{class: class, fails: 1}), 1
+}
+
+// wait sleeps for d or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
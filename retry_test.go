@@ -2,7 +2,11 @@ package retrygo_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -163,7 +167,7 @@ func TestDoContextCancel(t *testing.T) {
 	}
 
 	// Check if the error is a context deadline exceeded error.
-	if err != context.DeadlineExceeded {
+	if !errors.Is(err, context.DeadlineExceeded) {
 		t.Error("expected context deadline exceeded error")
 	}
 }
@@ -191,7 +195,7 @@ func TestDoContextCancelBeforeDo(t *testing.T) {
 	}
 
 	// Check if the error is a context canceled error.
-	if err != context.Canceled {
+	if !errors.Is(err, context.Canceled) {
 		t.Error("expected context canceled error")
 	}
 }
@@ -372,7 +376,7 @@ func TestDoRecoveryContextCancel(t *testing.T) {
 	}
 
 	// Check if the error is a context deadline exceeded error.
-	if err != context.DeadlineExceeded {
+	if !errors.Is(err, context.DeadlineExceeded) {
 		t.Error("expected context deadline exceeded error")
 	}
 }
@@ -402,7 +406,994 @@ func TestDoRecoveryContextCancelBeforeDo(t *testing.T) {
 	}
 
 	// Check if the error is a context canceled error.
-	if err != context.Canceled {
+	if !errors.Is(err, context.Canceled) {
 		t.Error("expected context canceled error")
 	}
 }
+
+type testError struct{ code int }
+
+func (e *testError) Error() string {
+	return fmt.Sprintf("test error %d", e.code)
+}
+
+func TestDoRetryIf(t *testing.T) {
+	errTemporary := fmt.Errorf("temporary")
+	errFatal := fmt.Errorf("fatal")
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(5),
+		retrygo.WithRetryIf[int](func(err error) bool {
+			return errors.Is(err, errTemporary)
+		}),
+	)
+
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errTemporary
+		}
+		return 0, fmt.Errorf("wrapped: %w", errFatal)
+	})
+	if !errors.Is(err, errFatal) {
+		t.Errorf("expected %v, got %v", errFatal, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDoRetryOn(t *testing.T) {
+	errTemporary := fmt.Errorf("temporary")
+	var fails []int
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			fails = append(fails, ri.Fails)
+			return ri.Fails < 5, 0
+		},
+		retrygo.WithRetryOn[int](errTemporary),
+	)
+
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errTemporary
+		}
+		return 0, fmt.Errorf("other")
+	})
+	if err == nil || errors.Is(err, errTemporary) {
+		t.Errorf("expected the non-retryable error, got %v", err)
+	}
+	// The non-retryable error must not reach the policy.
+	if len(fails) != 2 {
+		t.Errorf("expected the policy to be called 2 times, got %d", len(fails))
+	}
+}
+
+// Test that several WithRetryOn options retry the errors of any of them
+func TestDoRetryOnMultiple(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(5),
+		retrygo.WithRetryOn[int](errA),
+		retrygo.WithRetryOn[int](errB),
+	)
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		switch calls {
+		case 1:
+			return 0, errA
+		case 2:
+			return 0, errB
+		}
+		return 0, errors.New("other")
+	})
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopPermanent || calls != 3 {
+		t.Errorf("expected a permanent stop after 3 calls, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDoStopOn(t *testing.T) {
+	errFatal := fmt.Errorf("fatal")
+	for _, tc := range []struct {
+		name   string
+		target any
+		err    error
+	}{
+		{"Is", errFatal, fmt.Errorf("wrapped: %w", errFatal)},
+		{"As", new(*testError), fmt.Errorf("wrapped: %w", &testError{code: 1})},
+		{"AsInterface", new(interface{ Timeout() bool }), timeoutError{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, err := retrygo.New[int](
+				retrygo.LimitCount(5),
+				retrygo.WithStopOn[int](tc.target),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			_, err = retry.Do(context.Background(), func(context.Context) (int, error) {
+				calls++
+				if calls < 2 {
+					return 0, fmt.Errorf("temporary")
+				}
+				return 0, tc.err
+			})
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected %v, got %v", tc.err, err)
+			}
+			if calls != 2 {
+				t.Errorf("expected 2 calls, got %d", calls)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestStopOnInvalidTarget(t *testing.T) {
+	for _, target := range []any{nil, "fatal", testError{}, (**testError)(nil), new(int)} {
+		if _, err := retrygo.New[int](retrygo.LimitCount(1), retrygo.WithStopOn[int](target)); err == nil {
+			t.Errorf("expected error for target %#v", target)
+		}
+	}
+}
+
+func TestDoPermanent(t *testing.T) {
+	errFatal := fmt.Errorf("fatal")
+	policyCalls := 0
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			policyCalls++
+			return ri.Fails < 5, 0
+		},
+	)
+
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls < 2 {
+			return 0, fmt.Errorf("temporary")
+		}
+		return 0, fmt.Errorf("deep: %w", retrygo.Permanent(errFatal))
+	})
+	if !errors.Is(err, errFatal) {
+		t.Errorf("expected %v, got %v", errFatal, err)
+	}
+	if calls != 2 || policyCalls != 1 {
+		t.Errorf("expected 2 calls and 1 policy call, got %d and %d", calls, policyCalls)
+	}
+	if retrygo.Permanent(nil) != nil {
+		t.Error("expected Permanent(nil) to be nil")
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	const after = 50 * time.Millisecond
+	errThrottled := fmt.Errorf("throttled")
+	for _, tc := range []struct {
+		name    string
+		sleep   time.Duration
+		options []retrygo.RetryOption[int]
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"Exact", time.Hour, nil, after, time.Second},
+		{"Min", 0, []retrygo.RetryOption[int]{retrygo.WithRetryAfterMin[int]()}, after, time.Second},
+		{"MinLonger", 2 * after, []retrygo.RetryOption[int]{retrygo.WithRetryAfterMin[int]()}, 2 * after, time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, _ := retrygo.New[int](
+				retrygo.Combine(retrygo.LimitCount(2), retrygo.Constant(tc.sleep)),
+				tc.options...,
+			)
+			start := time.Now()
+			calls := 0
+			_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+				calls++
+				if calls < 2 {
+					return 0, retrygo.RetryAfter(errThrottled, after)
+				}
+				return 1, nil
+			})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed < tc.min || elapsed > tc.max {
+				t.Errorf("expected a sleep between %s and %s, got %s", tc.min, tc.max, elapsed)
+			}
+		})
+	}
+
+	var retryAfter retrygo.ErrRetryAfter
+	err := retrygo.RetryAfter(errThrottled, after)
+	if !errors.Is(err, errThrottled) || !errors.As(err, &retryAfter) || retryAfter.After != after {
+		t.Errorf("unexpected error %#v", err)
+	}
+}
+
+func TestDoExhausted(t *testing.T) {
+	errLast := fmt.Errorf("last")
+	retry, _ := retrygo.New[int](
+		retrygo.Combine(retrygo.LimitCount(4), retrygo.Constant(time.Millisecond)),
+		retrygo.WithHistory[int](2),
+	)
+
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls == 4 {
+			return 0, errLast
+		}
+		return 0, fmt.Errorf("error %d", calls)
+	})
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected *ErrExhausted, got %T", err)
+	}
+	if !errors.Is(err, errLast) {
+		t.Errorf("expected the error to wrap %v", errLast)
+	}
+	if exhausted.Reason != retrygo.StopPolicy {
+		t.Errorf("expected %s, got %s", retrygo.StopPolicy, exhausted.Reason)
+	}
+	if exhausted.Attempts != 4 {
+		t.Errorf("expected 4 attempts, got %d", exhausted.Attempts)
+	}
+	if exhausted.Slept < 3*time.Millisecond || exhausted.Elapsed < exhausted.Slept {
+		t.Errorf("unexpected durations: slept %s, elapsed %s", exhausted.Slept, exhausted.Elapsed)
+	}
+	if len(exhausted.History) != 2 ||
+		exhausted.History[0].Error() != "error 2" ||
+		exhausted.History[1].Error() != "error 3" {
+		t.Errorf("unexpected history %v", exhausted.History)
+	}
+}
+
+func TestDoExhaustedContext(t *testing.T) {
+	errLast := fmt.Errorf("last")
+	retry, _ := retrygo.New[int](retrygo.Constant(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := retry.Do(ctx, func(context.Context) (int, error) {
+		return 0, errLast
+	})
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopContext {
+		t.Fatalf("expected *ErrExhausted stopped by the context, got %v", err)
+	}
+	if !errors.Is(err, errLast) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap both %v and %v", errLast, context.DeadlineExceeded)
+	}
+	if exhausted.Attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", exhausted.Attempts)
+	}
+}
+
+func TestDoRetryInfo(t *testing.T) {
+	const sleep = 5 * time.Millisecond
+	var infos []retrygo.RetryInfo
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			infos = append(infos, ri)
+			return ri.Fails < 3, time.Duration(ri.Fails) * sleep
+		},
+	)
+
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		time.Sleep(sleep)
+		return 0, fmt.Errorf("error")
+	})
+
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 policy calls, got %d", len(infos))
+	}
+	var totalSleep time.Duration
+	for i, ri := range infos {
+		if ri.Attempt != i+1 || ri.Fails != i+1 {
+			t.Errorf("expected attempt and fails %d, got %d and %d", i+1, ri.Attempt, ri.Fails)
+		}
+		if ri.AttemptDuration < sleep {
+			t.Errorf("expected an attempt duration of at least %s, got %s", sleep, ri.AttemptDuration)
+		}
+		if ri.AttemptStart.Before(ri.Since) {
+			t.Errorf("attempt %d started before the retry", ri.Attempt)
+		}
+		if i > 0 && !ri.AttemptStart.After(infos[i-1].AttemptStart) {
+			t.Errorf("attempt %d did not start after the previous one", ri.Attempt)
+		}
+		if ri.LastSleep != time.Duration(i)*sleep {
+			t.Errorf("expected a last sleep of %s, got %s", time.Duration(i)*sleep, ri.LastSleep)
+		}
+		totalSleep += ri.LastSleep
+		if ri.TotalSleep != totalSleep {
+			t.Errorf("expected a total sleep of %s, got %s", totalSleep, ri.TotalSleep)
+		}
+	}
+}
+
+func TestDoErrorClass(t *testing.T) {
+	errs := []error{
+		&testError{code: 1},
+		fmt.Errorf("wrapped: %w", &testError{code: 2}),
+		fmt.Errorf("other"),
+		&testError{code: 3},
+		fmt.Errorf("another"),
+		errors.New("another"),
+	}
+	for _, tc := range []struct {
+		name     string
+		options  []retrygo.RetryOption[int]
+		expected string
+	}{
+		{
+			name:     "Default",
+			expected: `[*retrygo_test.testError 1 1] [*retrygo_test.testError 2 2] [*errors.errorString("other") 1 1] [*retrygo_test.testError 3 1] [*errors.errorString("another") 1 1] [*errors.errorString("another") 2 2]`,
+		},
+		{
+			name:     "Custom",
+			options:  []retrygo.RetryOption[int]{retrygo.WithErrorClass[int](func(err error) string { return err.Error() })},
+			expected: "[test error 1 1 1] [wrapped: test error 2 1 1] [other 1 1] [test error 3 1 1] [another 1 1] [another 2 2]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen []string
+			retry, _ := retrygo.New[int](
+				func(ri retrygo.RetryInfo) (bool, time.Duration) {
+					seen = append(seen, fmt.Sprint([]any{ri.Class, ri.ClassFails, ri.Consecutive}))
+					return ri.Fails < len(errs), 0
+				},
+				tc.options...,
+			)
+			calls := 0
+			_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+				calls++
+				return 0, errs[calls-1]
+			})
+			if actual := strings.Join(seen, " "); actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// Test the default class of the errors that wrap several errors
+func TestDoErrorClassMulti(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	errs := []error{
+		fmt.Errorf("%w: %w", retrygo.ErrAttemptTimeout, &testError{code: 1}),
+		fmt.Errorf("%w: %w", retrygo.ErrAttemptTimeout, &testError{code: 2}),
+		fmt.Errorf("%w: %w", retrygo.ErrAttemptTimeout, errA),
+		errors.Join(errA, errB),
+		errors.Join(errA, &testError{}),
+	}
+	var seen []string
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			seen = append(seen, fmt.Sprint([]any{ri.Class, ri.Consecutive}))
+			return ri.Fails < len(errs), 0
+		},
+	)
+	calls := 0
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		return 0, errs[calls-1]
+	})
+	expected := strings.Join([]string{
+		`[*errors.errorString("retrygo: attempt timed out") + *retrygo_test.testError 1]`,
+		`[*errors.errorString("retrygo: attempt timed out") + *retrygo_test.testError 2]`,
+		`[*errors.errorString("retrygo: attempt timed out") + *errors.errorString("a") 1]`,
+		`[*errors.errorString("a") + *errors.errorString("b") 1]`,
+		`[*errors.errorString("a") + *retrygo_test.testError 1]`,
+	}, " ")
+	if actual := strings.Join(seen, " "); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestDoDeadlineMode(t *testing.T) {
+	errLast := fmt.Errorf("last")
+	for _, tc := range []struct {
+		name     string
+		mode     retrygo.DeadlineMode
+		reason   retrygo.StopReason
+		attempts int
+		maxTime  time.Duration
+	}{
+		{"Ignore", retrygo.DeadlineIgnore, retrygo.StopContext, 1, time.Second},
+		{"Stop", retrygo.DeadlineStop, retrygo.StopDeadline, 1, 100 * time.Millisecond},
+		{"Truncate", retrygo.DeadlineTruncate, retrygo.StopDeadline, 2, time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, _ := retrygo.New[int](
+				retrygo.Constant(time.Hour),
+				retrygo.WithDeadlineMode[int](tc.mode),
+			)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := retry.Do(ctx, func(context.Context) (int, error) {
+				// The truncated sleep leaves room for an attempt this long.
+				time.Sleep(20 * time.Millisecond)
+				return 0, errLast
+			})
+
+			var exhausted *retrygo.ErrExhausted
+			if !errors.As(err, &exhausted) || exhausted.Reason != tc.reason {
+				t.Fatalf("expected to stop with %s, got %v", tc.reason, err)
+			}
+			if !errors.Is(err, errLast) {
+				t.Errorf("expected the error to wrap %v", errLast)
+			}
+			if tc.mode != retrygo.DeadlineIgnore && errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected to stop before the deadline, got %v", err)
+			}
+			if exhausted.Attempts != tc.attempts {
+				t.Errorf("expected %d attempts, got %d", tc.attempts, exhausted.Attempts)
+			}
+			if elapsed := time.Since(start); elapsed > tc.maxTime {
+				t.Errorf("expected to return within %s, got %s", tc.maxTime, elapsed)
+			}
+		})
+	}
+
+	if _, err := retrygo.New[int](retrygo.Constant(0), retrygo.WithDeadlineMode[int](42)); err == nil {
+		t.Error("expected error for an unknown deadline mode")
+	}
+}
+
+func TestDoAttemptTimeout(t *testing.T) {
+	errOther := fmt.Errorf("other")
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(5),
+		retrygo.WithAttemptTimeout[int](10*time.Millisecond),
+		// Timed-out attempts are retried even if the predicates reject them.
+		retrygo.WithRetryOn[int](errOther),
+	)
+
+	calls := 0
+	val, err := retry.Do(context.Background(), func(ctx context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return calls, nil
+	})
+	if err != nil || val != 3 {
+		t.Errorf("expected 3 and no error, got %d and %v", val, err)
+	}
+
+	_, err = retry.Do(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopPolicy {
+		t.Fatalf("expected the policy to give up, got %v", err)
+	}
+	if !errors.Is(err, retrygo.ErrAttemptTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap %v and %v, got %v", retrygo.ErrAttemptTimeout, context.DeadlineExceeded, err)
+	}
+
+	if _, err := retrygo.New[int](retrygo.LimitCount(1), retrygo.WithAttemptTimeout[int](0)); err == nil {
+		t.Error("expected error for a zero attempt timeout")
+	}
+}
+
+func TestDoAttemptTimeoutFunc(t *testing.T) {
+	const timeout = 10 * time.Millisecond
+	var attempts []int
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(3),
+		retrygo.WithAttemptTimeoutFunc[int](func(ri retrygo.RetryInfo) time.Duration {
+			attempts = append(attempts, ri.Attempt)
+			return timeout << ri.Attempt
+		}),
+	)
+
+	var durations []time.Duration
+	_, _ = retry.Do(context.Background(), func(ctx context.Context) (int, error) {
+		start := time.Now()
+		<-ctx.Done()
+		durations = append(durations, time.Since(start))
+		return 0, ctx.Err()
+	})
+	if len(durations) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(durations))
+	}
+	// The function gets the RetryInfo of the previous attempt.
+	if fmt.Sprint(attempts) != "[0 1 2]" {
+		t.Errorf("expected the timeout function to see attempts [0 1 2], got %v", attempts)
+	}
+	for i, d := range durations {
+		if expected := timeout << i; d < expected || d >= expected<<1 {
+			t.Errorf("attempt %d: expected a timeout of %s, got %s", i+1, expected, d)
+		}
+	}
+}
+
+func TestDoAttemptTimeoutParentContext(t *testing.T) {
+	retry, _ := retrygo.New[int](
+		retrygo.Constant(0),
+		retrygo.WithAttemptTimeout[int](time.Hour),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := retry.Do(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopContext {
+		t.Fatalf("expected the context to stop the retry, got %v", err)
+	}
+	if errors.Is(err, retrygo.ErrAttemptTimeout) {
+		t.Errorf("expected the parent context, not the attempt timeout, got %v", err)
+	}
+}
+
+func TestDoAbandonOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	abandoned := make(chan error, 1)
+	retry, _ := retrygo.New[int](
+		retrygo.Constant(0),
+		retrygo.WithAbandonOnCancel[int](func(val int, err error) {
+			abandoned <- fmt.Errorf("late %d: %w", val, err)
+		}),
+	)
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	calls := 0
+	_, err := retry.Do(ctx, func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, fmt.Errorf("first")
+		}
+		// Ignore the context.
+		<-release
+		return calls, fmt.Errorf("second")
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Do to return right after the context is done, got %s", elapsed)
+	}
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopContext {
+		t.Fatalf("expected the context to stop the retry, got %v", err)
+	}
+	if exhausted.Err == nil || exhausted.Err.Error() != "first" || exhausted.Attempts != 2 {
+		t.Errorf("expected the error of the first attempt after 2 attempts, got %v after %d", exhausted.Err, exhausted.Attempts)
+	}
+
+	// Only the abandoned attempt is left behind.
+	if after := runtime.NumGoroutine(); after > before+1 {
+		t.Errorf("expected at most %d goroutines, got %d", before+1, after)
+	}
+	close(release)
+	select {
+	case err := <-abandoned:
+		if err.Error() != "late 2: second" {
+			t.Errorf("unexpected late outcome %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the late outcome")
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected %d goroutines, got %d", before, after)
+	}
+}
+
+// Test that an attempt is abandoned when its own timeout expires, and that
+// the next attempt waits for it to return
+func TestDoAbandonOnAttemptTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var retried []error
+	var fails int
+	retry, _ := retrygo.New[int](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			fails = ri.Fails
+			return true, 0
+		},
+		retrygo.WithAttemptTimeout[int](time.Millisecond),
+		retrygo.WithAbandonOnCancel[int](nil),
+		retrygo.WithOnRetry[int](func(ri retrygo.RetryInfo, _ time.Duration) {
+			retried = append(retried, ri.Err)
+		}),
+	)
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var calls atomic.Int64
+	_, err := retry.Do(ctx, func(context.Context) (int, error) {
+		calls.Add(1)
+		// Ignore the context.
+		<-release
+		return 0, nil
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Do to return right after the context is done, got %s", elapsed)
+	}
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.Reason != retrygo.StopContext {
+		t.Fatalf("expected the context to stop the retry, got %v", err)
+	}
+	if strings.Contains(err.Error(), "abandoned") || !errors.Is(err, retrygo.ErrAttemptTimeout) {
+		t.Errorf("expected the timeout of the previous attempt, got %v", err)
+	}
+	if len(retried) < 2 || fails != len(retried) || exhausted.Attempts != len(retried)+1 {
+		t.Fatalf("expected the timed out attempts to be retried, got %d retries, %d fails and %d attempts", len(retried), fails, exhausted.Attempts)
+	}
+	for _, err := range retried {
+		if !errors.Is(err, retrygo.ErrAttemptTimeout) || strings.Contains(err.Error(), "abandoned") {
+			t.Errorf("expected an attempt timeout, got %v", err)
+		}
+	}
+
+	// The later attempts wait for the hung one, which is the only one left
+	// behind.
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the function to be called once, got %d", n)
+	}
+	// Give the timers of the attempt contexts time to exit.
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for runtime.NumGoroutine() > before+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before+1 {
+		t.Errorf("expected at most %d goroutines, got %d", before+1, after)
+	}
+	close(release)
+	deadline = time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected %d goroutines, got %d", before, after)
+	}
+}
+
+// Test that an attempt runs once the timed out attempt before it returns
+func TestDoAbandonOnAttemptTimeoutRecover(t *testing.T) {
+	var late []int
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(10),
+		retrygo.WithAttemptTimeout[int](10*time.Millisecond),
+		retrygo.WithAbandonOnCancel[int](func(val int, _ error) {
+			late = append(late, val)
+		}),
+	)
+	var calls atomic.Int64
+	val, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			time.Sleep(30 * time.Millisecond)
+		}
+		return int(n), nil
+	})
+	if err != nil || val != 2 {
+		t.Fatalf("expected the second call to succeed, got %d and %v", val, err)
+	}
+	// The late outcome is handled before the next call.
+	if fmt.Sprint(late) != "[1]" {
+		t.Errorf("expected the late outcome of the first call, got %v", late)
+	}
+}
+
+func TestDoAbandonOnCancelSuccess(t *testing.T) {
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(3),
+		retrygo.WithAbandonOnCancel[int](nil),
+	)
+	calls := 0
+	val, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, fmt.Errorf("error")
+		}
+		return calls, nil
+	})
+	if err != nil || val != 3 {
+		t.Errorf("expected 3 and no error, got %d and %v", val, err)
+	}
+}
+
+func TestDoAbandonOnCancelPanic(t *testing.T) {
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(3),
+		retrygo.WithAbandonOnCancel[int](nil),
+	)
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("expected the panic in the calling goroutine, got %v", v)
+		}
+	}()
+	_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+		panic("boom")
+	})
+}
+
+func TestDoRetryOnResult(t *testing.T) {
+	var results []any
+	retry, _ := retrygo.New[string](
+		func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			if !errors.Is(ri.Err, retrygo.ErrResultRejected) {
+				t.Errorf("expected %v, got %v", retrygo.ErrResultRejected, ri.Err)
+			}
+			results = append(results, ri.Result)
+			return ri.Fails < 3, 0
+		},
+		retrygo.WithRetryOnResult[string](func(s string) bool { return s == "pending" }),
+		// Rejected results are retried even if the predicates reject them.
+		retrygo.WithRetryOn[string](context.Canceled),
+	)
+
+	calls := 0
+	val, err := retry.Do(context.Background(), func(context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "pending", nil
+		}
+		return "done", nil
+	})
+	if err != nil || val != "done" {
+		t.Errorf("expected done and no error, got %s and %v", val, err)
+	}
+	if fmt.Sprint(results) != "[pending pending]" {
+		t.Errorf("unexpected results %v", results)
+	}
+
+	// When retries run out, the last result is returned.
+	val, err = retry.Do(context.Background(), func(context.Context) (string, error) {
+		return "pending", nil
+	})
+	if !errors.Is(err, retrygo.ErrResultRejected) || val != "pending" {
+		t.Errorf("expected pending and %v, got %s and %v", retrygo.ErrResultRejected, val, err)
+	}
+}
+
+func TestDoDiscard(t *testing.T) {
+	var discarded []int
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(4),
+		retrygo.WithRetryOnResult[int](func(v int) bool { return v%2 == 0 }),
+		retrygo.WithDiscard[int](func(v int) { discarded = append(discarded, v) }),
+	)
+
+	// Failed and rejected results are discarded, the returned one is not.
+	calls := 0
+	val, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 1, fmt.Errorf("error")
+		}
+		return calls * 2, nil
+	})
+	if !errors.Is(err, retrygo.ErrResultRejected) || val != 8 {
+		t.Errorf("expected 8 and %v, got %d and %v", retrygo.ErrResultRejected, val, err)
+	}
+	if fmt.Sprint(discarded) != "[1 4 6]" {
+		t.Errorf("expected [1 4 6] to be discarded, got %v", discarded)
+	}
+
+	// The last result is dropped when the context is done.
+	discarded = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	val, err = retry.Do(ctx, func(context.Context) (int, error) {
+		cancel()
+		return 42, fmt.Errorf("error")
+	})
+	if !errors.Is(err, context.Canceled) || val != 0 {
+		t.Errorf("expected 0 and %v, got %d and %v", context.Canceled, val, err)
+	}
+	if fmt.Sprint(discarded) != "[42]" {
+		t.Errorf("expected [42] to be discarded, got %v", discarded)
+	}
+}
+
+func TestDoDiscardAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	discarded := make(chan int, 2)
+	retry, _ := retrygo.New[int](
+		retrygo.Constant(0),
+		retrygo.WithAbandonOnCancel[int](nil),
+		retrygo.WithDiscard[int](func(v int) { discarded <- v }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls := 0
+	_, _ = retry.Do(ctx, func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 1, fmt.Errorf("error")
+		}
+		<-release
+		return 2, nil
+	})
+	close(release)
+
+	for _, expected := range []int{1, 2} {
+		select {
+		case v := <-discarded:
+			if v != expected {
+				t.Errorf("expected %d to be discarded, got %d", expected, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d to be discarded", expected)
+		}
+	}
+}
+
+func TestDoFallback(t *testing.T) {
+	errLast := fmt.Errorf("last")
+	var discarded []string
+	fallback := func(ctx context.Context, err error, ri retrygo.RetryInfo) (string, error) {
+		if !errors.Is(err, errLast) {
+			return "", fmt.Errorf("unexpected error %v", err)
+		}
+		return fmt.Sprintf("cached after %d", ri.Attempt), nil
+	}
+	retry, _ := retrygo.New[string](
+		retrygo.LimitCount(2),
+		retrygo.WithFallback[string](fallback),
+		retrygo.WithDiscard[string](func(s string) { discarded = append(discarded, s) }),
+	)
+
+	val, err := retry.Do(context.Background(), func(context.Context) (string, error) {
+		return "partial", errLast
+	})
+	var exhausted *retrygo.ErrExhausted
+	if !errors.Is(err, retrygo.ErrFallbackUsed) || !errors.As(err, &exhausted) || !errors.Is(err, errLast) {
+		t.Errorf("expected the error to wrap %v and the *ErrExhausted, got %v", retrygo.ErrFallbackUsed, err)
+	}
+	if val != "cached after 2" {
+		t.Errorf("expected the fallback result, got %s", val)
+	}
+	if fmt.Sprint(discarded) != "[partial partial]" {
+		t.Errorf("expected both results to be discarded, got %v", discarded)
+	}
+
+	// The fallback does not run on success.
+	val, err = retry.Do(context.Background(), func(context.Context) (string, error) {
+		return "fresh", nil
+	})
+	if err != nil || val != "fresh" {
+		t.Errorf("expected fresh and no error, got %s and %v", val, err)
+	}
+
+	// The fallback does not run on context cancellation by default.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := retry.Do(ctx, func(context.Context) (string, error) { return "", errLast }); errors.Is(err, retrygo.ErrFallbackUsed) {
+		t.Errorf("expected no fallback, got %v", err)
+	}
+}
+
+func TestDoFallbackOnCancel(t *testing.T) {
+	retry, _ := retrygo.New[string](
+		retrygo.Constant(time.Hour),
+		retrygo.WithFallback[string](func(ctx context.Context, err error, ri retrygo.RetryInfo) (string, error) {
+			return "default", nil
+		}),
+		retrygo.WithFallbackOnCancel[string](),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	val, err := retry.Do(ctx, func(context.Context) (string, error) {
+		return "", fmt.Errorf("error")
+	})
+	if val != "default" || !errors.Is(err, retrygo.ErrFallbackUsed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the fallback after the deadline, got %s and %v", val, err)
+	}
+}
+
+func TestDoFallbackFailed(t *testing.T) {
+	errFallback := fmt.Errorf("no cache")
+	errLast := fmt.Errorf("last")
+	retry, _ := retrygo.New[string](
+		retrygo.LimitCount(1),
+		retrygo.WithFallback[string](func(ctx context.Context, err error, ri retrygo.RetryInfo) (string, error) {
+			return "", errFallback
+		}),
+	)
+	_, err := retry.Do(context.Background(), func(context.Context) (string, error) {
+		return "", retrygo.Permanent(errLast)
+	})
+	if !errors.Is(err, errFallback) || !errors.Is(err, errLast) || !errors.Is(err, retrygo.ErrFallbackUsed) {
+		t.Errorf("expected the error to wrap %v, %v and %v, got %v", errFallback, errLast, retrygo.ErrFallbackUsed, err)
+	}
+	expected := "retrygo: fallback used, but failed: no cache: retrygo: permanent error after 1 attempts: last"
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %v", expected, err)
+	}
+}
+
+func TestDoRecoveryError(t *testing.T) {
+	errPanic := fmt.Errorf("panic error")
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(1),
+		retrygo.WithRecovery[int](),
+	)
+
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		panic(errPanic)
+	})
+	var recovered retrygo.ErrRecovered
+	if !errors.As(err, &recovered) {
+		t.Fatalf("expected ErrRecovered, got %v", err)
+	}
+	if !errors.Is(err, errPanic) {
+		t.Errorf("expected the error to wrap %v", errPanic)
+	}
+	if recovered.Error() != "recovered: panic error" {
+		t.Errorf("unexpected message %q", recovered.Error())
+	}
+	if !strings.Contains(string(recovered.Stack), "TestDoRecoveryError") {
+		t.Errorf("expected the stack trace of the panic, got %s", recovered.Stack)
+	}
+}
+
+func TestDoRepanic(t *testing.T) {
+	var discarded []int
+	retry, _ := retrygo.New[int](
+		retrygo.LimitCount(5),
+		retrygo.WithRecovery[int](),
+		retrygo.WithRepanic[int](retrygo.IsRuntimeError),
+		retrygo.WithDiscard[int](func(v int) { discarded = append(discarded, v) }),
+	)
+
+	calls := 0
+	func() {
+		defer func() {
+			v := recover()
+			if !retrygo.IsRuntimeError(v) {
+				t.Errorf("expected a runtime error, got %v", v)
+			}
+		}()
+		_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+			calls++
+			if calls == 1 {
+				// An application panic is retried.
+				panic("application")
+			}
+			if calls == 2 {
+				return 2, fmt.Errorf("error")
+			}
+			var m map[string]int
+			m["boom"]++ // A runtime error is re-panicked.
+			return 0, nil
+		})
+	}()
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	// The result of the last failed attempt is discarded before re-panicking.
+	if fmt.Sprint(discarded) != "[0 2]" {
+		t.Errorf("expected [0 2] to be discarded, got %v", discarded)
+	}
+}
@@ -0,0 +1,68 @@
+package retrygo
+
+import (
+	"fmt"
+	"time"
+)
+
+// Case is a branch of Switch.
+type Case struct {
+	Match  func(error) bool // Match reports whether the case handles the error, nil matches any error
+	Policy RetryPolicy      // Policy is the policy of the case, nil means no retry
+}
+
+// CaseIs returns a Case for the errors that match target (errors.Is).
+func CaseIs(target error, policy RetryPolicy) Case {
+	return Case{Match: MatchIs(target), Policy: policy}
+}
+
+// CaseAs returns a Case for the errors that have an error of type E in their
+// chain (errors.As). E must be an error or an interface type, CaseAs panics
+// otherwise.
+func CaseAs[E any](policy RetryPolicy) Case {
+	return Case{Match: MatchAs[E](), Policy: policy}
+}
+
+// CaseFunc returns a Case for the errors for which pred returns true.
+func CaseFunc(pred func(error) bool, policy RetryPolicy) Case {
+	return Case{Match: pred, Policy: policy}
+}
+
+// CaseDefault returns a Case for any error. Put it last.
+func CaseDefault(policy RetryPolicy) Case {
+	return Case{Policy: policy}
+}
+
+// Switch returns a RetryPolicy that routes every failure to the first case
+// that matches RetryInfo.Err. Every case counts its own failures: its policy
+// sees RetryInfo.Fails as the number of failures handled by the case in the
+// current Do call. The function will return false if no case matches, the
+// case has no policy or its policy returns false. The returned policy is
+// stateful, don't use it twice in a policy tree, see Stateful.
+//
+// Sleep formula: sleep of the matching case
+func Switch(cases ...Case) RetryPolicy {
+	return Stateful(PolicyFunc(func() RetryPolicy {
+		fails := make([]int, len(cases))
+		return func(ri RetryInfo) (bool, time.Duration) {
+			for i, c := range cases {
+				if c.Match != nil && !c.Match(ri.Err) {
+					continue
+				}
+				if c.Policy == nil {
+					ri.call.record(Decision{Reason: fmt.Sprintf("case %d of Switch does not retry", i+1)})
+					return false, 0
+				}
+				fails[i]++
+				ri.Fails = fails[i]
+				continueRetry, sleep := c.Policy(ri)
+				if !continueRetry {
+					ri.call.record(Decision{Reason: fmt.Sprintf("case %d of Switch gave up", i+1)})
+				}
+				return continueRetry, sleep
+			}
+			ri.call.record(Decision{Reason: "no case of Switch matches the error"})
+			return false, 0
+		}
+	}))
+}
@@ -0,0 +1,93 @@
+package retrygo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// Test Switch
+func TestSwitch(t *testing.T) {
+	errThrottled := fmt.Errorf("throttled")
+	errAuth := fmt.Errorf("auth")
+
+	// recording returns a policy that records the fails it sees.
+	var seen []string
+	recording := func(name string, limit int, sleep time.Duration) retrygo.RetryPolicy {
+		return func(ri retrygo.RetryInfo) (bool, time.Duration) {
+			seen = append(seen, fmt.Sprintf("%s:%d", name, ri.Fails))
+			return ri.Fails < limit, sleep
+		}
+	}
+	policy := retrygo.Switch(
+		retrygo.CaseIs(errThrottled, recording("throttled", 10, time.Hour)),
+		retrygo.CaseIs(errAuth, nil),
+		retrygo.CaseAs[*testError](recording("test", 10, 0)),
+		retrygo.CaseFunc(func(err error) bool { return err.Error() == "reset" }, recording("reset", 2, 0)),
+		retrygo.CaseDefault(recording("default", 10, 0)),
+	)
+
+	errs := []error{
+		&testError{code: 1},
+		fmt.Errorf("other"),
+		&testError{code: 2},
+		fmt.Errorf("reset"),
+		fmt.Errorf("reset"),
+	}
+	retry, _ := retrygo.New[int](policy)
+	calls := 0
+	_, err := retry.Do(context.Background(), func(context.Context) (int, error) {
+		calls++
+		return 0, errs[calls-1]
+	})
+
+	var exhausted *retrygo.ErrExhausted
+	if !errors.As(err, &exhausted) || exhausted.PolicyReason != "case 4 of Switch gave up" || exhausted.Attempts != 5 {
+		t.Errorf("expected reset to give up after 5 attempts, got %v", err)
+	}
+	expected := fmt.Sprint([]string{"test:1", "default:1", "test:2", "reset:1", "reset:2"})
+	if fmt.Sprint(seen) != expected {
+		t.Errorf("expected %s, got %v", expected, seen)
+	}
+
+	// The sleep comes from the matching case.
+	if continueRetry, sleep := policy(retrygo.RetryInfo{Fails: 1, Err: errThrottled}); !continueRetry || sleep != time.Hour {
+		t.Errorf("expected true and %s, got %t and %s", time.Hour, continueRetry, sleep)
+	}
+}
+
+// Test Switch reasons
+func TestSwitchReason(t *testing.T) {
+	errAuth := fmt.Errorf("auth")
+	policy := retrygo.Switch(
+		retrygo.CaseIs(errAuth, nil),
+		retrygo.CaseAs[interface{ Timeout() bool }](retrygo.Constant(0)),
+	)
+	for _, tc := range []struct {
+		err    error
+		reason string
+	}{
+		{fmt.Errorf("wrapped: %w", errAuth), "case 1 of Switch does not retry"},
+		{fmt.Errorf("other"), "no case of Switch matches the error"},
+		{timeoutError{}, ""},
+	} {
+		d := policy.Decide(retrygo.RetryInfo{Fails: 1, Err: tc.err})
+		if d.Continue != (tc.reason == "") || d.Reason != tc.reason {
+			t.Errorf("%v: unexpected decision %+v", tc.err, d)
+		}
+	}
+}
+
+// Test CaseAs with an invalid type
+func TestCaseAsInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	retrygo.CaseAs[int](nil)
+}
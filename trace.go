@@ -0,0 +1,248 @@
+package retrygo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Tracer starts the spans of the Retry instances, see WithTracer. The
+// interface is shaped after OpenTelemetry, so an adapter to it is a thin
+// shim around trace.Tracer.
+type Tracer interface {
+	// Start starts a span as a child of the span of ctx, if any, and returns
+	// a context that carries the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. Span is used from the goroutine of Do
+// only.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attribute)
+	// AddEvent adds an event to the span.
+	AddEvent(name string, attrs ...Attribute)
+	// RecordError records an error of the span and marks the span as failed.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Attribute is an attribute of a span or of an event. Value is an int, a
+// string or a time.Duration.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Span names and attribute keys used by WithTracer.
+const (
+	SpanDo      = "retrygo.Do"      // SpanDo is the name of the span of a Do call
+	SpanAttempt = "retrygo.attempt" // SpanAttempt is the name of the span of an attempt
+	EventSleep  = "retrygo.sleep"   // EventSleep is the name of the event of a sleep before a retry
+
+	AttrName         = "retrygo.name"          // AttrName is the name of the Retry, see WithName
+	AttrAttempt      = "retrygo.attempt"       // AttrAttempt is the number of the attempt
+	AttrAttempts     = "retrygo.attempts"      // AttrAttempts is the number of attempts of a Do call
+	AttrError        = "retrygo.error"         // AttrError is the error of an attempt
+	AttrSleep        = "retrygo.sleep"         // AttrSleep is the sleep planned after an attempt
+	AttrReason       = "retrygo.reason"        // AttrReason is the stop reason of a Do call that gave up
+	AttrPolicyReason = "retrygo.policy_reason" // AttrPolicyReason is the reason given by the policy, if any
+)
+
+// WithTracer traces every Do call with a span, and every attempt with a
+// child span. The context passed to the function carries the span of the
+// attempt, so the spans of the function are its children.
+//
+// The span of an attempt has the attributes retrygo.attempt, retrygo.error
+// if the attempt failed, and retrygo.sleep if it is going to be retried. The
+// sleeps are events of the span of the Do call.
+func WithTracer[T any](tracer Tracer) RetryOption[T] {
+	return option[T]{
+		f: func(r *Retry[T]) error {
+			if tracer == nil {
+				return errors.New("retrygo: nil tracer")
+			}
+			r.tracer = tracer
+			return nil
+		},
+	}
+}
+
+// trace is the tracing of a Do call. A nil *trace traces nothing.
+type trace struct {
+	tracer  Tracer
+	span    Span // span is the span of the Do call
+	attempt Span // attempt is the span of the current attempt, nil between attempts
+}
+
+// startTrace starts the span of a Do call, if there is a tracer.
+func startTrace(ctx context.Context, tracer Tracer, name string) (context.Context, *trace) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := tracer.Start(ctx, SpanDo)
+	span.SetAttributes(Attribute{Key: AttrName, Value: name})
+	return ctx, &trace{tracer: tracer, span: span}
+}
+
+// startAttempt starts the span of an attempt and returns its context.
+func (t *trace) startAttempt(ctx context.Context, attempt int) context.Context {
+	if t == nil {
+		return ctx
+	}
+	ctx, t.attempt = t.tracer.Start(ctx, SpanAttempt)
+	t.attempt.SetAttributes(Attribute{Key: AttrAttempt, Value: attempt})
+	return ctx
+}
+
+// failed records the error of the current attempt.
+func (t *trace) failed(err error) {
+	if t == nil || t.attempt == nil {
+		return
+	}
+	t.attempt.SetAttributes(Attribute{Key: AttrError, Value: err.Error()})
+	t.attempt.RecordError(err)
+}
+
+// retry ends the span of the current attempt with the planned sleep and adds
+// the sleep to the span of the Do call.
+func (t *trace) retry(ri RetryInfo, sleep time.Duration) {
+	if t == nil {
+		return
+	}
+	if t.attempt != nil {
+		t.attempt.SetAttributes(Attribute{Key: AttrSleep, Value: sleep})
+	}
+	t.endAttempt()
+	t.span.AddEvent(EventSleep,
+		Attribute{Key: AttrAttempt, Value: ri.Attempt},
+		Attribute{Key: AttrSleep, Value: sleep},
+	)
+}
+
+// success ends the span of the last attempt.
+func (t *trace) success(ri RetryInfo) {
+	if t == nil {
+		return
+	}
+	t.endAttempt()
+	t.span.SetAttributes(Attribute{Key: AttrAttempts, Value: ri.Attempt})
+}
+
+// giveUp ends the span of the last attempt and records why Do gave up.
+func (t *trace) giveUp(err *ErrExhausted) {
+	if t == nil {
+		return
+	}
+	t.endAttempt()
+	attrs := []Attribute{
+		{Key: AttrAttempts, Value: err.Attempts},
+		{Key: AttrReason, Value: err.Reason.String()},
+	}
+	if err.PolicyReason != "" {
+		attrs = append(attrs, Attribute{Key: AttrPolicyReason, Value: err.PolicyReason})
+	}
+	t.span.SetAttributes(attrs...)
+	t.span.RecordError(err)
+}
+
+func (t *trace) endAttempt() {
+	if t.attempt != nil {
+		t.attempt.End()
+		t.attempt = nil
+	}
+}
+
+// end ends the spans that are still open, e.g. when the function panics.
+func (t *trace) end() {
+	if t == nil {
+		return
+	}
+	t.endAttempt()
+	t.span.End()
+}
+
+// RecordingTracer is a Tracer that keeps the spans in memory, e.g. for tests.
+// The zero value is ready to use.
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span recorded by RecordingTracer.
+type RecordedSpan struct {
+	Name       string      // Name is the name of the span
+	Parent     int         // Parent is the index of the parent span, -1 for a root span
+	Attributes []Attribute // Attributes are the attributes of the span, in order
+	Events     []SpanEvent // Events are the events of the span, in order
+	Errors     []error     // Errors are the recorded errors
+	Ended      bool        // Ended tells whether the span ended
+
+	tracer *RecordingTracer
+	index  int
+}
+
+// SpanEvent is an event of a RecordedSpan.
+type SpanEvent struct {
+	Name       string
+	Attributes []Attribute
+}
+
+// recordedSpanKey is the context key of the span of a RecordingTracer.
+type recordedSpanKey struct{}
+
+// NewRecordingTracer returns an empty RecordingTracer.
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+func (t *RecordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &RecordedSpan{Name: name, Parent: -1, tracer: t, index: len(t.spans)}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*RecordedSpan); ok && parent.tracer == t {
+		span.Parent = parent.index
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns a copy of the recorded spans, in the order they started.
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]RecordedSpan, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = *span
+		spans[i].Attributes = append([]Attribute(nil), span.Attributes...)
+		spans[i].Events = append([]SpanEvent(nil), span.Events...)
+		spans[i].Errors = append([]error(nil), span.Errors...)
+	}
+	return spans
+}
+
+func (s *RecordedSpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+func (s *RecordedSpan) AddEvent(name string, attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Events = append(s.Events, SpanEvent{Name: name, Attributes: attrs})
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *RecordedSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Ended = true
+}
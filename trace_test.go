@@ -0,0 +1,117 @@
+package retrygo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// formatSpan formats a recorded span for comparison.
+func formatSpan(span retrygo.RecordedSpan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s parent=%d ended=%t", span.Name, span.Parent, span.Ended)
+	for _, attr := range span.Attributes {
+		fmt.Fprintf(&b, " %s=%v", attr.Key, attr.Value)
+	}
+	for _, event := range span.Events {
+		fmt.Fprintf(&b, " event:%s", event.Name)
+		for _, attr := range event.Attributes {
+			fmt.Fprintf(&b, ":%s=%v", attr.Key, attr.Value)
+		}
+	}
+	fmt.Fprintf(&b, " errors=%d", len(span.Errors))
+	return b.String()
+}
+
+// Test the spans of WithTracer
+func TestTracer(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		fail           int
+		requiredValues []string
+	}{
+		{
+			name: "Success",
+			fail: 1,
+			requiredValues: []string{
+				"retrygo.Do parent=-1 ended=true retrygo.name=fetch retrygo.attempts=2 event:retrygo.sleep:retrygo.attempt=1:retrygo.sleep=1ms errors=0",
+				"retrygo.attempt parent=0 ended=true retrygo.attempt=1 retrygo.error=boom retrygo.sleep=1ms errors=1",
+				"retrygo.attempt parent=0 ended=true retrygo.attempt=2 errors=0",
+			},
+		},
+		{
+			name: "GiveUp",
+			fail: 10,
+			requiredValues: []string{
+				"retrygo.Do parent=-1 ended=true retrygo.name=fetch retrygo.attempts=2 retrygo.reason=policy gave up retrygo.policy_reason=count limit 2 reached event:retrygo.sleep:retrygo.attempt=1:retrygo.sleep=1ms errors=1",
+				"retrygo.attempt parent=0 ended=true retrygo.attempt=1 retrygo.error=boom retrygo.sleep=1ms errors=1",
+				"retrygo.attempt parent=0 ended=true retrygo.attempt=2 retrygo.error=boom errors=1",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracer := retrygo.NewRecordingTracer()
+			retry, err := retrygo.New[int](
+				retrygo.Combine(retrygo.LimitCount(2), retrygo.Constant(time.Millisecond)),
+				retrygo.WithName[int]("fetch"),
+				retrygo.WithTracer[int](tracer),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := 0
+			_, _ = retry.Do(context.Background(), func(ctx context.Context) (int, error) {
+				calls++
+				// The spans of the function are children of the attempt.
+				_, span := tracer.Start(ctx, "child")
+				span.End()
+				if calls <= tc.fail {
+					return 0, errors.New("boom")
+				}
+				return calls, nil
+			})
+			var actual []string
+			for _, span := range tracer.Spans() {
+				if span.Name == "child" {
+					if parent := tracer.Spans()[span.Parent]; parent.Name != retrygo.SpanAttempt {
+						t.Errorf("expected the child of an attempt, got the child of %s", parent.Name)
+					}
+					continue
+				}
+				actual = append(actual, formatSpan(span))
+			}
+			if len(actual) != len(tc.requiredValues) {
+				t.Fatalf("expected %d spans, got %d: %q", len(tc.requiredValues), len(actual), actual)
+			}
+			for i, required := range tc.requiredValues {
+				if actual[i] != required {
+					t.Errorf("expected %s, got %s", required, actual[i])
+				}
+			}
+		})
+	}
+}
+
+// Test that WithTracer ends the spans when the function panics
+func TestTracerPanic(t *testing.T) {
+	tracer := retrygo.NewRecordingTracer()
+	retry, _ := retrygo.New[int](retrygo.LimitCount(2), retrygo.WithTracer[int](tracer))
+	func() {
+		defer func() { _ = recover() }()
+		_, _ = retry.Do(context.Background(), func(context.Context) (int, error) {
+			panic("boom")
+		})
+	}()
+	spans := tracer.Spans()
+	if len(spans) != 2 || !spans[0].Ended || !spans[1].Ended {
+		t.Errorf("expected 2 ended spans, got %+v", spans)
+	}
+	if _, err := retrygo.New[int](retrygo.Constant(0), retrygo.WithTracer[int](nil)); err == nil {
+		t.Error("expected an error for a nil tracer")
+	}
+}
@@ -0,0 +1,62 @@
+package retrygo
+
+import (
+	"math"
+	"time"
+)
+
+// transform returns a RetryPolicy that changes the sleep of the policy with f
+// when the policy continues. The continue decision is passed through
+// unchanged.
+func transform(policy RetryPolicy, f func(time.Duration) time.Duration) RetryPolicy {
+	return func(ri RetryInfo) (bool, time.Duration) {
+		continueRetry, sleep := policy(ri)
+		if !continueRetry {
+			return false, sleep
+		}
+		return true, f(sleep)
+	}
+}
+
+// Clamp returns a RetryPolicy that keeps the sleep of the policy between
+// lower and upper.
+//
+// Sleep formula: min(max(sleep, lower), upper)
+func Clamp(policy RetryPolicy, lower, upper time.Duration) RetryPolicy {
+	return transform(policy, func(sleep time.Duration) time.Duration {
+		return min(max(sleep, lower), upper)
+	})
+}
+
+// Scale returns a RetryPolicy that multiplies the sleep of the policy by
+// factor. The sleep saturates instead of overflowing.
+//
+// Sleep formula: sleep * factor
+func Scale(policy RetryPolicy, factor float64) RetryPolicy {
+	return transform(policy, func(sleep time.Duration) time.Duration {
+		return saturate(float64(sleep) * factor)
+	})
+}
+
+// Shift returns a RetryPolicy that adds offset to the sleep of the policy.
+// The offset may be negative, the sleep never is. The sleep saturates instead
+// of overflowing.
+//
+// Sleep formula: max(sleep + offset, 0)
+func Shift(policy RetryPolicy, offset time.Duration) RetryPolicy {
+	return transform(policy, func(sleep time.Duration) time.Duration {
+		if offset > 0 && sleep > math.MaxInt64-offset {
+			return math.MaxInt64
+		}
+		return max(sleep+offset, 0)
+	})
+}
+
+// Floor returns a RetryPolicy that never sleeps less than d.
+//
+// Sleep formula: max(sleep, d)
+func Floor(policy RetryPolicy, d time.Duration) RetryPolicy {
+	return transform(policy, func(sleep time.Duration) time.Duration {
+		return max(sleep, d)
+	})
+}
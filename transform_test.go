@@ -0,0 +1,84 @@
+package retrygo_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ic-it/retrygo"
+)
+
+// Test Clamp, Scale, Shift and Floor
+func TestTransformers(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		backoff        retrygo.RetryPolicy
+		requiredValues []time.Duration
+	}{
+		{
+			name:           "Clamp",
+			backoff:        retrygo.Clamp(retrygo.Exponential(100*time.Millisecond), 200*time.Millisecond, time.Second),
+			requiredValues: []time.Duration{200 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second},
+		},
+		{
+			name:           "Scale",
+			backoff:        retrygo.Scale(retrygo.Linear(time.Second), 0.5),
+			requiredValues: []time.Duration{500 * time.Millisecond, time.Second, 1500 * time.Millisecond},
+		},
+		{
+			name:           "ScaleSaturate",
+			backoff:        retrygo.Scale(retrygo.Constant(time.Hour), math.MaxFloat64),
+			requiredValues: []time.Duration{math.MaxInt64},
+		},
+		{
+			name:           "Shift",
+			backoff:        retrygo.Shift(retrygo.Linear(time.Second), -1500*time.Millisecond),
+			requiredValues: []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		},
+		{
+			name:           "ShiftSaturate",
+			backoff:        retrygo.Shift(retrygo.Constant(math.MaxInt64-1), time.Second),
+			requiredValues: []time.Duration{math.MaxInt64},
+		},
+		{
+			name:           "Floor",
+			backoff:        retrygo.Floor(retrygo.Linear(time.Second), 2*time.Second),
+			requiredValues: []time.Duration{2 * time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name: "Combine",
+			backoff: retrygo.Combine(
+				retrygo.Floor(retrygo.Constant(0), time.Second),
+				retrygo.Scale(retrygo.Fibonacci(time.Second), 2),
+			),
+			requiredValues: []time.Duration{3 * time.Second, 3 * time.Second, 5 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := retrygo.RetryInfo{Fails: 1}
+			for _, expectedValue := range tc.requiredValues {
+				continueRetry, sleep := tc.backoff(info)
+				if !continueRetry || sleep != expectedValue {
+					t.Errorf("expected true and %s, got %t and %s", expectedValue, continueRetry, sleep)
+				}
+				info.Fails++
+			}
+		})
+	}
+}
+
+// Test the transformers pass the stop decision through
+func TestTransformersStop(t *testing.T) {
+	policy := retrygo.LimitCount(1)
+	for name, transformed := range map[string]retrygo.RetryPolicy{
+		"Clamp": retrygo.Clamp(policy, time.Second, time.Minute),
+		"Scale": retrygo.Scale(policy, 2),
+		"Shift": retrygo.Shift(policy, time.Second),
+		"Floor": retrygo.Floor(policy, time.Second),
+	} {
+		d := transformed.Decide(retrygo.RetryInfo{Fails: 1})
+		if d.Continue || d.Sleep != 0 || d.Reason != "count limit 1 reached" {
+			t.Errorf("%s: expected the stop decision of the policy, got %+v", name, d)
+		}
+	}
+}